@@ -0,0 +1,149 @@
+// Copyright 2019-20 PJ Engineering and Business Solutions Pty. Ltd. All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rocketlaunchr/dbq/v2"
+)
+
+const src = `package models
+
+type Base struct {
+	ID int ` + "`dbq:\"id\"`" + `
+}
+
+type user struct {
+	Base
+	FirstName string
+	Email     string            ` + "`dbq:\"email,omitempty\"`" + `
+	Meta      map[string]string
+	Hidden    string            ` + "`dbq:\"-\"`" + `
+	internal  int
+}
+
+type other struct {
+	Name string
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbqgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "models.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgName, structs, err := parseDir(dir, nil, "dbq", dbq.SnakeCase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkgName != "models" || len(structs) != 2 || structs[0].name != "Base" || structs[1].name != "user" {
+		t.Fatalf("wrong structs found: %s %v", pkgName, structs)
+	}
+
+	out, err := generate(pkgName, structs[1:], dbq.PostgreSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`const userColumns = "\"id\", \"first_name\", \"email\""`,
+		`var userColumnNames = []string{"id", "first_name", "email"}`,
+		`return []interface{}{&u.Base.ID, &u.FirstName, &u.Email}`,
+		`columns, values = append(columns, "id"), append(values, u.Base.ID)`,
+		`if v := reflect.ValueOf(&u.Email).Elem(); !reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface()) {`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(string(out), e) {
+			t.Errorf("expected generated code to contain %q:\n%s", e, out)
+		}
+	}
+}
+
+func TestGenerateEmbedded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbqgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := "package models\n\nimport \"time\"\n\ntype user struct {\n\ttime.Time\n\tID int `dbq:\"id\"`\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "models.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = parseDir(dir, nil, "dbq", nil)
+	if err == nil || !strings.Contains(err.Error(), "time.Time") {
+		t.Fatalf("expected error for unsupported embedded field: %v", err)
+	}
+}
+
+func TestGenerateNested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbqgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `package models
+
+type address struct {
+	City string ` + "`dbq:\"city\"`" + `
+}
+
+type point struct {
+	X, Y float64
+}
+
+func (p *point) Scan(src interface{}) error { return nil }
+
+type user struct {
+	ID       int        ` + "`dbq:\"id\"`" + `
+	Address  address    ` + "`dbq:\"address\"`" + `
+	Billing  *address
+	Orders   []address
+	Settings struct{ Theme string }
+	Location point      ` + "`dbq:\"location\"`" + `
+}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "models.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings bytes.Buffer
+	stderr = &warnings
+	defer func() { stderr = os.Stderr }()
+
+	_, structs, err := parseDir(dir, []string{"user"}, "dbq", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var columns []string
+	for _, f := range structs[0].fields {
+		columns = append(columns, f.column)
+	}
+
+	if strings.Join(columns, ",") != "id,location" {
+		t.Errorf("wrong columns: %v", columns)
+	}
+
+	for _, field := range []string{"Address", "Billing", "Orders", "Settings"} {
+		if !strings.Contains(warnings.String(), "skipping field "+field+" ") {
+			t.Errorf("expected warning for %s: %s", field, warnings.String())
+		}
+	}
+}
@@ -4,14 +4,22 @@ package dbq
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"cloud.google.com/go/civil"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/go-sql-driver/mysql"
 	"github.com/mitchellh/mapstructure"
+	"golang.org/x/xerrors"
 )
 
 type AnyTime struct{}
@@ -159,7 +167,7 @@ func TestMustE(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// This is for batch Insert with MySQL
-	mock.ExpectExec("INSERT INTO store").
+	mock.ExpectExec("INSERT INTO `store`").
 		WithArgs(
 			int64(6), "Dish Washer", float64(45534.34), int64(34), int64(1), AnyTime{},
 			int64(7), "Sewing Machine", float64(9843.35), int64(8), int64(0), AnyTime{},
@@ -167,7 +175,7 @@ func TestMustE(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// This is for batch insert with PostgreSQL
-	mock.ExpectExec("INSERT INTO store").
+	mock.ExpectExec(`INSERT INTO "store"`).
 		WithArgs(
 			int64(6), "Dish Washer", float64(45534.34), int64(34), int64(1), AnyTime{},
 			int64(7), "Sewing Machine", float64(9843.35), int64(8), int64(0), AnyTime{},
@@ -455,3 +463,1301 @@ func TestSingleQueryRawResult(t *testing.T) {
 		t.Errorf("wrong val: expected: %T %v actual: %T %v", expected, expected, actual, actual)
 	}
 }
+
+func TestNameMapper(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type product struct {
+		ID         int64
+		UnitPrice  float64
+		StockCount int64 `dbq:"quantity"`
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "unit_price", "quantity"}).
+		AddRow(int64(1), float64(45000.98), int64(6)).
+		AddRow(int64(2), float64(25089.55), int64(10))
+
+	expected := []*product{
+		{ID: 1, UnitPrice: 45000.98, StockCount: 6},
+		{ID: 2, UnitPrice: 25089.55, StockCount: 10},
+	}
+
+	mock.ExpectQuery("^SELECT (.+) FROM store$").WillReturnRows(rows)
+
+	ctx := context.Background()
+
+	opts := &Options{ConcreteStruct: product{}, NameMapper: SnakeCase}
+
+	actual := MustQ(ctx, db, "SELECT * FROM store", opts)
+
+	if !cmp.Equal(expected, actual) {
+		t.Errorf("wrong val: expected: %T %v actual: %T %v", expected, expected, actual, actual)
+	}
+
+	cols := StructColumns(product{ID: 1}, SnakeCase)
+	if !cmp.Equal([]string{"id", "unit_price", "quantity"}, cols) {
+		t.Errorf("wrong columns: %v", cols)
+	}
+
+	for in, out := range map[string]string{"ID": "id", "UserID": "user_id", "HTTPServer": "http_server", "Address2City": "address2_city"} {
+		if actual := SnakeCase(in); actual != out {
+			t.Errorf("SnakeCase(%q): expected: %q actual: %q", in, out, actual)
+		}
+	}
+
+	for in, out := range map[string]string{"ID": "id", "UserID": "userID", "HTTPServer": "httpServer", "CreatedAt": "createdAt"} {
+		if actual := CamelCase(in); actual != out {
+			t.Errorf("CamelCase(%q): expected: %q actual: %q", in, out, actual)
+		}
+	}
+}
+
+func TestCaseInsensitiveColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type user struct {
+		ID   int64
+		Name string
+	}
+
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "a"))
+
+	actual := MustQ(context.Background(), db, "SELECT * FROM users", &Options{ConcreteStruct: user{}})
+
+	expected := []*user{{ID: 1, Name: "a"}}
+	if !cmp.Equal(expected, actual) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, actual))
+	}
+
+	// Closures created from the same function literal must not share a cached mapping
+	prefixMapper := func(prefix string) NameMapper {
+		return func(fieldName string) string { return prefix + SnakeCase(fieldName) }
+	}
+
+	for _, prefix := range []string{"a_", "b_"} {
+		rows := sqlmock.NewRows([]string{"a_id", "a_name", "b_id", "b_name"}).AddRow(int64(1), "a", int64(2), "b")
+		mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(rows)
+
+		actual := MustQ(context.Background(), db, "SELECT * FROM users", &Options{ConcreteStruct: user{}, NameMapper: prefixMapper(prefix)})
+
+		expected := []*user{{ID: 1, Name: "a"}}
+		if prefix == "b_" {
+			expected = []*user{{ID: 2, Name: "b"}}
+		}
+		if !cmp.Equal(expected, actual) {
+			t.Errorf("wrong val for prefix %s: %s", prefix, cmp.Diff(expected, actual))
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNestedStruct(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type address struct {
+		City     string `dbq:"city"`
+		PostCode string `dbq:"post_code"`
+	}
+
+	type Base struct {
+		ID int64 `dbq:"id"`
+	}
+
+	type user struct {
+		Base
+		Name     string   `dbq:"name"`
+		Address  *address `dbq:"address"`
+		Billing  address  `dbq:"billing,prefix=billing_"`
+		Shipping *address `dbq:"shipping"`
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "name", "address.city", "address.post_code", "billing_city", "shipping.city", "shipping.post_code"}).
+		AddRow(int64(1), "Sally", "Sydney", "2000", "Perth", "Hobart", nil).
+		AddRow(int64(2), "Peter", nil, nil, "Darwin", nil, nil)
+
+	expected := []*user{
+		{
+			Base:     Base{ID: 1},
+			Name:     "Sally",
+			Address:  &address{City: "Sydney", PostCode: "2000"},
+			Billing:  address{City: "Perth"},
+			Shipping: &address{City: "Hobart"},
+		},
+		{
+			Base:    Base{ID: 2},
+			Name:    "Peter",
+			Billing: address{City: "Darwin"},
+		},
+	}
+
+	mock.ExpectQuery("^SELECT (.+) FROM users u JOIN addresses a (.+)$").WillReturnRows(rows)
+
+	ctx := context.Background()
+
+	actual := MustQ(ctx, db, "SELECT * FROM users u JOIN addresses a ON u.id = a.user_id", &Options{ConcreteStruct: user{}})
+
+	if !cmp.Equal(expected, actual) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, actual))
+	}
+}
+
+type aggOption struct {
+	ID    int64  `dbq:"id,pk"`
+	Value string `dbq:"value"`
+}
+
+type aggItem struct {
+	ID      int64       `dbq:"id,pk"`
+	Name    string      `dbq:"name"`
+	Options []aggOption `dbq:"options"`
+}
+
+type aggOrder struct {
+	ID       int64      `dbq:"id,pk"`
+	Customer string     `dbq:"customer"`
+	Items    []*aggItem `dbq:"items"`
+	Count    int        `dbq:"-"`
+}
+
+func (o *aggOrder) PostUnmarshal(ctx context.Context, row, count int) error {
+	o.Count = count
+	return nil
+}
+
+func TestAggregate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "customer", "items.id", "items.name", "items.options.id", "items.options.value"}).
+		AddRow(int64(2), "Peter", int64(10), "shirt", int64(100), "red").
+		AddRow(int64(2), "Peter", int64(10), "shirt", int64(101), "large").
+		AddRow(int64(1), "Sally", int64(11), "shoes", nil, nil).
+		AddRow(int64(2), "Peter", int64(12), "hat", int64(102), "blue").
+		AddRow(int64(3), "Tom", nil, nil, nil, nil)
+
+	expected := []*aggOrder{
+		{
+			ID:       2,
+			Customer: "Peter",
+			Items: []*aggItem{
+				{ID: 10, Name: "shirt", Options: []aggOption{{ID: 100, Value: "red"}, {ID: 101, Value: "large"}}},
+				{ID: 12, Name: "hat", Options: []aggOption{{ID: 102, Value: "blue"}}},
+			},
+			Count: 3,
+		},
+		{
+			ID:       1,
+			Customer: "Sally",
+			Items:    []*aggItem{{ID: 11, Name: "shoes"}},
+			Count:    3,
+		},
+		{
+			ID:       3,
+			Customer: "Tom",
+			Count:    3,
+		},
+	}
+
+	mock.ExpectQuery("^SELECT (.+) FROM orders (.+)$").WillReturnRows(rows)
+
+	ctx := context.Background()
+
+	actual := MustQ(ctx, db, "SELECT * FROM orders o LEFT JOIN items i ON o.id = i.order_id LEFT JOIN options p ON i.id = p.item_id", &Options{ConcreteStruct: aggOrder{}, Aggregate: true})
+
+	if !cmp.Equal(expected, actual) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, actual))
+	}
+
+	// No primary key
+	mock.ExpectQuery("^SELECT (.+) FROM store$").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+
+	_, err = Q(ctx, db, "SELECT * FROM store", &Options{ConcreteStruct: store{}, Aggregate: true})
+	if err != ErrNoPrimaryKey {
+		t.Errorf("expected ErrNoPrimaryKey: %v", err)
+	}
+}
+
+type preloadPost struct {
+	ID     int64  `dbq:"id,pk"`
+	UserID int64  `dbq:"user_id"`
+	Title  string `dbq:"title"`
+}
+
+type preloadUser struct {
+	ID     int           `dbq:"id,pk"`
+	Name   string        `dbq:"name"`
+	Posts  []preloadPost `dbq:"-"`
+	Latest *preloadPost  `dbq:"-"`
+}
+
+func TestPreload(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	users := []*preloadUser{{ID: 1, Name: "Sally"}, {ID: 2, Name: "Peter"}, {ID: 3, Name: "Tom"}}
+
+	mock.ExpectQuery(`^SELECT \* FROM "posts" WHERE \(deleted = \$1\) AND "user_id" IN \(\$2,\$3\) ORDER BY id$`).
+		WithArgs(false, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title"}).
+			AddRow(int64(10), int64(1), "a").
+			AddRow(int64(11), int64(2), "b").
+			AddRow(int64(12), int64(1), "c"))
+
+	mock.ExpectQuery(`^SELECT \* FROM "posts" WHERE \(deleted = \$1\) AND "user_id" IN \(\$2\) ORDER BY id$`).
+		WithArgs(false, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title"}))
+
+	mock.ExpectQuery("^SELECT `id`,`user_id`,`title` FROM `posts` WHERE `user_id` IN \\( \\?,\\?,\\? \\) ORDER BY id DESC$").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title"}).
+			AddRow(int64(12), int64(1), "c").
+			AddRow(int64(11), int64(2), "b").
+			AddRow(int64(10), int64(1), "a"))
+
+	ctx := context.Background()
+
+	err = Preload(ctx, db, users, nil,
+		Relation{
+			Field:      "Posts",
+			Table:      "posts",
+			ForeignKey: "user_id",
+			Where:      "deleted = $1",
+			Args:       []interface{}{false},
+			OrderBy:    "id",
+			ChunkSize:  2,
+			DBType:     PostgreSQL,
+		},
+		Relation{
+			Field:      "Latest",
+			Table:      "posts",
+			Columns:    []string{"id", "user_id", "title"},
+			ForeignKey: "user_id",
+			OrderBy:    "id DESC",
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []*preloadUser{
+		{ID: 1, Name: "Sally", Posts: []preloadPost{{10, 1, "a"}, {12, 1, "c"}}, Latest: &preloadPost{12, 1, "c"}},
+		{ID: 2, Name: "Peter", Posts: []preloadPost{{11, 2, "b"}}, Latest: &preloadPost{11, 2, "b"}},
+		{ID: 3, Name: "Tom"},
+	}
+
+	if !cmp.Equal(expected, users) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, users))
+	}
+
+	// Options that change the type of Q's results are ignored
+	mock.ExpectQuery("^SELECT \\* FROM `posts` WHERE `user_id` IN \\( \\?,\\?,\\? \\)$").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title"}).
+			AddRow(int64(11), int64(2), "b"))
+
+	err = Preload(ctx, db, users, &Options{KeyBy: "id", GroupByKey: true, SingleResult: true}, Relation{
+		Field:      "Latest",
+		Table:      "posts",
+		ForeignKey: "user_id",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if users[0].Latest != nil || !cmp.Equal(&preloadPost{11, 2, "b"}, users[1].Latest) {
+		t.Errorf("wrong val: %v %v", users[0].Latest, users[1].Latest)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScanPlan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type row struct {
+		ID        uint8       `dbq:"id"`
+		Name      *string     `dbq:"name"`
+		Price     float32     `dbq:"price"`
+		Available bool        `dbq:"available"`
+		Quantity  *int        `dbq:"quantity"`
+		DateAdded time.Time   `dbq:"date_added"`
+		Released  civil.Date  `dbq:"released"`
+		Raw       []byte      `dbq:"raw"`
+		Extra     interface{} `dbq:"extra"`
+	}
+
+	tRef := time.Date(2020, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	rows := sqlmock.NewRows([]string{"id", "name", "price", "available", "quantity", "date_added", "released", "raw", "extra", "unknown"}).
+		AddRow("1", "watch", "45.5", int64(1), int64(6), tRef, "2020-01-31", []byte("abc"), "x", "y").
+		AddRow(int64(2), nil, float64(25), "false", nil, "2020-03-01 10:30:00", tRef, nil, nil, nil)
+
+	expected := []*row{
+		{ID: 1, Name: &[]string{"watch"}[0], Price: 45.5, Available: true, Quantity: &[]int{6}[0], DateAdded: tRef, Released: civil.Date{Year: 2020, Month: 1, Day: 31}, Raw: []byte("abc"), Extra: "x"},
+		{ID: 2, Price: 25, DateAdded: tRef, Released: civil.Date{Year: 2020, Month: 3, Day: 1}},
+	}
+
+	mock.ExpectQuery("^SELECT (.+) FROM store$").WillReturnRows(rows)
+
+	actual := MustQ(context.Background(), db, "SELECT * FROM store", &Options{ConcreteStruct: row{}})
+
+	if !cmp.Equal(expected, actual) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, actual))
+	}
+
+	// Overflow
+	mock.ExpectQuery("^SELECT (.+) FROM store$").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(300)))
+
+	_, err = Q(context.Background(), db, "SELECT * FROM store", &Options{ConcreteStruct: row{}})
+	if err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+
+	// A strict DecoderConfig is honoured
+	mock.ExpectQuery("^SELECT (.+) FROM store$").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+
+	_, err = Q(context.Background(), db, "SELECT * FROM store", &Options{ConcreteStruct: row{}, DecoderConfig: &StructorConfig{WeaklyTypedInput: false}})
+	if err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+}
+
+func benchmarkQ(b *testing.B, opts *Options) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	tRef := time.Now()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		rows := sqlmock.NewRows([]string{"id", "product", "price", "quantity", "available", "date_added"})
+		for j := 0; j < 100; j++ {
+			rows.AddRow(int64(j), "wrist watch", float64(45000.98), int64(6), int64(1), tRef)
+		}
+		mock.ExpectQuery("^SELECT (.+) FROM store$").WillReturnRows(rows)
+		b.StartTimer()
+
+		if _, err := Q(ctx, db, "SELECT * FROM store", opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkQScanPlan scans directly into the struct's fields.
+func BenchmarkQScanPlan(b *testing.B) {
+	benchmarkQ(b, &Options{ConcreteStruct: store{}})
+}
+
+// BenchmarkQMapstructure uses the mapstructure package because a DecodeHook is provided.
+func BenchmarkQMapstructure(b *testing.B) {
+	benchmarkQ(b, &Options{ConcreteStruct: store{}, DecoderConfig: &StructorConfig{
+		DecodeHook:       mapstructure.StringToTimeHookFunc(time.RFC3339),
+		WeaklyTypedInput: true}})
+}
+
+type cents int64
+
+func (c *cents) Scan(src interface{}) error {
+	f, err := strconv.ParseFloat(fmt.Sprint(src), 64)
+	if err != nil {
+		return err
+	}
+	*c = cents(math.Round(f * 100))
+	return nil
+}
+
+type tags []string
+
+func (t *tags) UnmarshalText(text []byte) error {
+	*t = strings.Split(string(text), ",")
+	return nil
+}
+
+func (t tags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+func TestScannerFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type row struct {
+		Name  sql.NullString `dbq:"name"`
+		Stock sql.NullInt64  `dbq:"stock"`
+		Price cents          `dbq:"price"`
+		Cost  *cents         `dbq:"cost"`
+		Tags  tags           `dbq:"tags"`
+	}
+
+	expected := []*row{
+		{Name: sql.NullString{String: "watch", Valid: true}, Stock: sql.NullInt64{Int64: 6, Valid: true}, Price: 4550, Cost: &[]cents{1200}[0], Tags: tags{"a", "b"}},
+		{Price: 2500},
+	}
+
+	for _, opts := range []*Options{
+		{ConcreteStruct: row{}},
+		{ConcreteStruct: row{}, DecoderConfig: StdTimeConversionConfig()}, // mapstructure
+	} {
+		rows := sqlmock.NewRows([]string{"name", "stock", "price", "cost", "tags"}).
+			AddRow("watch", int64(6), "45.5", float64(12), "a,b").
+			AddRow(nil, nil, "25", nil, nil)
+
+		mock.ExpectQuery("^SELECT (.+) FROM store$").WillReturnRows(rows)
+
+		actual := MustQ(context.Background(), db, "SELECT * FROM store", opts)
+
+		if !cmp.Equal(expected, actual) {
+			t.Errorf("wrong val: %s", cmp.Diff(expected, actual))
+		}
+	}
+}
+
+func TestResultSet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"name", "id", "id"}).
+		AddRow("Sally", int64(1), int64(10)).
+		AddRow("Peter", int64(2), int64(20))
+
+	mock.ExpectQuery("^SELECT (.+) FROM users u JOIN posts p (.+)$").WillReturnRows(rows)
+
+	rs := MustQR(context.Background(), db, "SELECT u.name, u.id, p.id FROM users u JOIN posts p ON u.id = p.user_id", nil)
+
+	if !cmp.Equal([]string{"name", "id", "id"}, rs.ColumnNames()) {
+		t.Errorf("wrong columns: %v", rs.ColumnNames())
+	}
+
+	if rs.Len() != 2 || len(rs.Columns()) != 3 {
+		t.Fatalf("wrong size: %d rows %d columns", rs.Len(), len(rs.Columns()))
+	}
+
+	expected := [][]interface{}{
+		{&[]string{"Sally"}[0], &[]string{"1"}[0], &[]string{"10"}[0]},
+		{&[]string{"Peter"}[0], &[]string{"2"}[0], &[]string{"20"}[0]},
+	}
+
+	if !cmp.Equal(expected, rs.Rows()) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, rs.Rows()))
+	}
+
+	if rs.ColumnIndex("id") != 1 || rs.ColumnIndex("missing") != -1 {
+		t.Errorf("wrong column index")
+	}
+
+	if v := rs.Value(1, "id"); *v.(*string) != "2" {
+		t.Errorf("wrong value: %v", v)
+	}
+
+	if m := rs.Map(0); *m["id"].(*string) != "1" {
+		t.Errorf("wrong map value: %v", m)
+	}
+}
+
+func TestExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"name", "id", "bio"}).
+			AddRow("Sally", int64(1), nil).
+			AddRow("Peter; \"Pete\"", int64(2), "Likes\ncats")
+	}
+
+	// CSV
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(newRows())
+
+	var b strings.Builder
+	err = ExportCSV(context.Background(), db, &b, "SELECT name, id, bio FROM users", &CSVOptions{Comma: ';', NullString: "NULL"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "name;id;bio\nSally;1;NULL\n\"Peter; \"\"Pete\"\"\";2;\"Likes\ncats\"\n"
+	if b.String() != expected {
+		t.Errorf("wrong csv: %s", cmp.Diff(expected, b.String()))
+	}
+
+	// JSON
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(newRows())
+
+	b.Reset()
+	err = ExportJSON(context.Background(), db, &b, "SELECT name, id, bio FROM users", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected = `[{"name":"Sally","id":"1","bio":null},{"name":"Peter; \"Pete\"","id":"2","bio":"Likes\ncats"}]`
+	if b.String() != expected {
+		t.Errorf("wrong json: %s", cmp.Diff(expected, b.String()))
+	}
+
+	// NDJSON
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(newRows())
+
+	b.Reset()
+	err = ExportNDJSON(context.Background(), db, &b, "SELECT name, id, bio FROM users", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected = "{\"name\":\"Sally\",\"id\":\"1\",\"bio\":null}\n{\"name\":\"Peter; \\\"Pete\\\"\",\"id\":\"2\",\"bio\":\"Likes\\ncats\"}\n"
+	if b.String() != expected {
+		t.Errorf("wrong ndjson: %s", cmp.Diff(expected, b.String()))
+	}
+
+	// Duplicate column names
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(sqlmock.NewRows([]string{"id", "id"}).AddRow(int64(1), int64(2)))
+
+	b.Reset()
+	err = ExportJSON(context.Background(), db, &b, "SELECT u.id, p.id FROM users", nil)
+	if err == nil || !strings.Contains(err.Error(), "duplicate column name id") {
+		t.Errorf("expected duplicate column error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScalarHelpers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// QScalar
+	mock.ExpectQuery("^SELECT name FROM users WHERE id = \\?$").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Sally"))
+
+	var name string
+	if err := QScalar(ctx, db, &name, "SELECT name FROM users WHERE id = ?", nil, 1); err != nil || name != "Sally" {
+		t.Errorf("wrong scalar: %v %v", name, err)
+	}
+
+	mock.ExpectQuery("^SELECT name FROM users WHERE id = \\?$").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	if err := QScalar(ctx, db, &name, "SELECT name FROM users WHERE id = ?", nil, 2); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows: %v", err)
+	}
+
+	mock.ExpectQuery("^SELECT age FROM users WHERE id = \\?$").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"age"}).AddRow(nil))
+
+	age := &[]int{5}[0]
+	if err := QScalar(ctx, db, &age, "SELECT age FROM users WHERE id = ?", nil, 1); err != nil || age != nil {
+		t.Errorf("expected nil: %v %v", age, err)
+	}
+
+	// QColumn
+	mock.ExpectQuery("^SELECT id FROM users WHERE age > \\?$").WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow(int64(3)))
+
+	var ids []int64
+	if err := QColumn(ctx, db, &ids, "SELECT id FROM users WHERE age > ?", nil, 18); err != nil || !cmp.Equal([]int64{1, 3}, ids) {
+		t.Errorf("wrong column: %v %v", ids, err)
+	}
+
+	// QExists
+	mock.ExpectQuery("^SELECT EXISTS \\(SELECT 1 FROM users WHERE id = \\?\\)$").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(int64(1)))
+
+	if exists := MustQExists(ctx, db, "SELECT 1 FROM users WHERE id = ?", nil, 1); !exists {
+		t.Errorf("expected exists")
+	}
+
+	// QCount
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM users$").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow([]byte("42")))
+
+	if count := MustQCount(ctx, db, "SELECT COUNT(*) FROM users", nil); count != 42 {
+		t.Errorf("wrong count: %d", count)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestKeyBy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type user struct {
+		ID   int    `dbq:"id"`
+		Name string `dbq:"name"`
+		Team string `dbq:"team"`
+	}
+
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "team"}).
+			AddRow(1, "Sally", "red").
+			AddRow(2, "Peter", "blue").
+			AddRow(3, "Tom", "red")
+	}
+
+	ctx := context.Background()
+
+	// Unique (ConcreteStruct)
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(newRows())
+
+	byID := MustQ(ctx, db, "SELECT * FROM users", &Options{ConcreteStruct: user{}, KeyBy: "id"}).(map[int]*user)
+	if len(byID) != 3 || byID[2].Name != "Peter" {
+		t.Errorf("wrong keyed results: %v", byID)
+	}
+
+	// Grouped (ConcreteStruct)
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(newRows())
+
+	byTeam := MustQ(ctx, db, "SELECT * FROM users", &Options{ConcreteStruct: user{}, KeyBy: "team", GroupByKey: true}).(map[string][]*user)
+	if len(byTeam) != 2 || len(byTeam["red"]) != 2 || byTeam["red"][1].Name != "Tom" {
+		t.Errorf("wrong grouped results: %v", byTeam)
+	}
+
+	// Duplicate keys
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(newRows())
+
+	_, err = Q(ctx, db, "SELECT * FROM users", &Options{ConcreteStruct: user{}, KeyBy: "team", ErrOnDuplicateKey: true})
+	if !xerrors.Is(err, ErrDuplicateKey) {
+		t.Errorf("expected ErrDuplicateKey: %v", err)
+	}
+
+	// Map mode
+	mock.ExpectQuery("^SELECT (.+) FROM users$").WillReturnRows(newRows())
+
+	byName := MustQ(ctx, db, "SELECT * FROM users", &Options{KeyBy: "name"}).(map[interface{}]map[string]interface{})
+	if len(byName) != 3 || byName["Tom"] == nil {
+		t.Errorf("wrong keyed results: %v", byName)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBulkInsert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type Audit struct {
+		CreatedBy string
+	}
+
+	type user struct {
+		ID     int    `dbq:"id,omitempty"`
+		Name   string `dbq:"name"`
+		Age    int    `dbq:"age,omitempty"`
+		Secret string `dbq:"-"`
+		Tags   tags   `dbq:"tags"`
+		Meta   map[string]string
+		Audit
+	}
+
+	users := []*user{
+		{Name: "Brad", Age: 45, Tags: tags{"a", "b"}, Audit: Audit{"admin"}},
+		{Name: "Ange", Audit: Audit{"admin"}},
+	}
+
+	mock.ExpectExec(`^INSERT INTO "users" \( "name","age","tags","created_by" \) VALUES \( \$1,\$2,\$3,\$4 \),\( \$5,DEFAULT,\$6,\$7 \)$`).
+		WithArgs("Brad", 45, "a,b", "admin", "Ange", "", "admin").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	res := MustBulkInsert(context.Background(), db, users, BulkInsertOptions{Table: "users", DBType: PostgreSQL, NameMapper: SnakeCase})
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("wrong rows affected: %d", n)
+	}
+
+	// Tagged fields that can't be bound
+	type invalid struct {
+		Name string            `dbq:"name"`
+		Meta map[string]string `dbq:"meta"`
+	}
+
+	_, err = BulkInsert(context.Background(), db, []invalid{{Name: "Brad"}}, BulkInsertOptions{Table: "users"})
+	if err == nil || !strings.Contains(err.Error(), "driver.Valuer") {
+		t.Errorf("expected error for map field: %v", err)
+	}
+
+	// rows must be a slice of structs
+	for _, rows := range []interface{}{user{}, []int{1, 2}, nil} {
+		if _, err := BulkInsert(context.Background(), db, rows, BulkInsertOptions{Table: "users"}); err == nil {
+			t.Errorf("was expecting an error for %T, but there was none.", rows)
+		}
+
+		if _, _, err := StructRows(rows, nil); err == nil {
+			t.Errorf("was expecting an error for %T, but there was none.", rows)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBulkInsertChunks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := []interface{}{
+		[]interface{}{"Brad", 45},
+		[]interface{}{"Ange", 36},
+		[]interface{}{"Emily", 22},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `users` \\( `name`,`age` \\) VALUES \\( \\?,\\? \\),\\( \\?,\\? \\)$").
+		WithArgs("Brad", 45, "Ange", 36).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("^INSERT INTO `users` \\( `name`,`age` \\) VALUES \\( \\?,\\? \\)$").
+		WithArgs("Emily", 22).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	opts := BulkInsertOptions{Table: "users", MaxPlaceholders: 5, Atomic: true}
+	res := MustBulkInsertRows(context.Background(), db, []string{"name", "age"}, rows, opts)
+
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: %d", n)
+	}
+
+	if id, _ := res.LastInsertId(); id != 1 {
+		t.Errorf("wrong last insert id: %d", id)
+	}
+
+	// Failed chunk is rolled back
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `users` (.+)$").WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("^INSERT INTO `users` (.+)$").WillReturnError(fmt.Errorf("insert failed"))
+	mock.ExpectRollback()
+
+	if _, err := BulkInsertRows(context.Background(), db, []string{"name", "age"}, rows, opts); err == nil {
+		t.Errorf("expected error")
+	}
+
+	// Cancelled between chunks
+	mock.ExpectExec("^INSERT INTO `users` (.+)$").WillReturnResult(sqlmock.NewResult(1, 2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts = BulkInsertOptions{Table: "users", MaxPlaceholders: 5}
+	if _, err := BulkInsertRows(ctx, cancelExecer{db, cancel}, []string{"name", "age"}, rows, opts); err != context.Canceled {
+		t.Errorf("expected context.Canceled: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// cancelExecer cancels a context after each statement is executed.
+type cancelExecer struct {
+	ExecContexter
+	cancel func()
+}
+
+func (c cancelExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer c.cancel()
+	return c.ExecContexter.ExecContext(ctx, query, args...)
+}
+
+func TestBulkLoad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type user struct {
+		Name string `dbq:"name"`
+		Age  *int   `dbq:"age"`
+	}
+
+	age := 45
+	users := []user{{"Brad", &age}, {"Ange", nil}, {"Emily", nil}}
+
+	// Multi-row inserts (channel)
+	mock.ExpectExec("^INSERT INTO `users` \\( `name`,`age` \\) VALUES \\( \\?,\\? \\),\\( \\?,\\? \\)$").
+		WithArgs("Brad", &age, "Ange", nil).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("^INSERT INTO `users` \\( `name`,`age` \\) VALUES \\( \\?,\\? \\)$").
+		WithArgs("Emily", nil).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	ch := make(chan user)
+	go func() {
+		defer close(ch)
+		for _, u := range users {
+			ch <- u
+		}
+	}()
+
+	res := MustBulkLoad(context.Background(), db, ch, BulkLoadOptions{Table: "users", MaxPlaceholders: 4})
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: %d", n)
+	}
+
+	// COPY FROM STDIN (iterator)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(`^COPY "users" \("name","age"\) FROM STDIN$`)
+	for _, u := range users {
+		prep.ExpectExec().WithArgs(u.Name, u.Age).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	prep.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	var i int
+	iter := func() (interface{}, error) {
+		if i == len(users) {
+			return nil, io.EOF
+		}
+		i++
+		return &users[i-1], nil
+	}
+
+	res = MustBulkLoad(context.Background(), db, RowIterator(iter), BulkLoadOptions{Table: "users", DBType: PostgreSQL, CopyIn: true})
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: %d", n)
+	}
+
+	// LOAD DATA LOCAL INFILE (slice)
+	var registered, deregistered string
+	opts := BulkLoadOptions{
+		Table:                   "users",
+		RegisterReaderHandler:   func(name string, handler func() io.Reader) { registered = name },
+		DeregisterReaderHandler: func(name string) { deregistered = name },
+	}
+
+	mock.ExpectExec("^LOAD DATA LOCAL INFILE 'Reader::dbq_[0-9]+' INTO TABLE `users` (.+) \\(`name`,`age`\\)$").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	res = MustBulkLoad(context.Background(), db, users, opts)
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: %d", n)
+	}
+
+	if registered == "" || registered != deregistered {
+		t.Errorf("reader handler was not registered and deregistered: %q %q", registered, deregistered)
+	}
+
+	// Tagged fields that can't be bound
+	type invalid struct {
+		Name string  `dbq:"name"`
+		Meta []int64 `dbq:"meta"`
+	}
+
+	_, err = BulkLoad(context.Background(), db, []invalid{{Name: "Brad"}}, BulkLoadOptions{Table: "users"})
+	if err == nil || !strings.Contains(err.Error(), "driver.Valuer") {
+		t.Errorf("expected error for slice field: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWriteLoadData(t *testing.T) {
+	age := 45
+	rows := [][]interface{}{
+		{"tab\there", &age, true, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"line\nbreak \\ slash", (*int)(nil), false, nil},
+		{sql.NullString{}, []byte("bytes"), 1.5, sql.NullString{String: "valid", Valid: true}},
+	}
+
+	var i int
+	next := func() ([]interface{}, error) {
+		if i == len(rows) {
+			return nil, io.EOF
+		}
+		i++
+		return rows[i-1], nil
+	}
+
+	var b strings.Builder
+	if err := writeLoadData(&b, next); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "tab\\there\t45\t1\t2020-01-02 03:04:05\n" +
+		"line\\nbreak \\\\ slash\t\\N\t0\t\\N\n" +
+		"\\N\tbytes\t1.5\tvalid\n"
+
+	if b.String() != expected {
+		t.Errorf("wrong output: %q", b.String())
+	}
+}
+
+func TestTxSavepoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^SAVEPOINT dbq_sp_[0-9]+$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO orders").WillReturnError(fmt.Errorf("insert failed"))
+	mock.ExpectExec("^ROLLBACK TO SAVEPOINT dbq_sp_[0-9]+$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^RELEASE SAVEPOINT dbq_sp_[0-9]+$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^SAVEPOINT dbq_sp_[0-9]+$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO logs").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^RELEASE SAVEPOINT dbq_sp_[0-9]+$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = Tx(ctx, db, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) {
+		if _, err := E(ctx, "INSERT INTO users", nil); err != nil {
+			return
+		}
+
+		// Inner rollback
+		err := Tx(ctx, tx, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) {
+			if _, err := E(ctx, "INSERT INTO orders", nil); err != nil {
+				return
+			}
+			txCommit()
+		})
+		if err != nil {
+			return
+		}
+
+		// Inner commit
+		err = Tx(ctx, tx, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) {
+			if _, err := E(ctx, "INSERT INTO logs", nil); err != nil {
+				return
+			}
+			txCommit()
+		})
+		if err != nil {
+			return
+		}
+
+		txCommit()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUPSERTStmt(t *testing.T) {
+	cols := []string{"id", "name", "age"}
+
+	tests := []struct {
+		conflict []string
+		update   []string
+		dbtype   Database
+		expected string
+	}{
+		{[]string{"id"}, []string{"name", "age"}, MySQL, "INSERT INTO `users` ( `id`,`name`,`age` ) VALUES ( ?,?,? ),( ?,?,? ) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`),`age` = VALUES(`age`)"},
+		{nil, nil, MySQL, "INSERT INTO `users` ( `id`,`name`,`age` ) VALUES ( ?,?,? ),( ?,?,? ) ON DUPLICATE KEY UPDATE `id` = `id`"},
+		{[]string{"id"}, []string{"name", "age"}, PostgreSQL, `INSERT INTO "users" ( "id","name","age" ) VALUES ($1,$2,$3),($4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name","age" = EXCLUDED."age"`},
+		{[]string{"id"}, nil, PostgreSQL, `INSERT INTO "users" ( "id","name","age" ) VALUES ($1,$2,$3),($4,$5,$6) ON CONFLICT ("id") DO NOTHING`},
+	}
+
+	for i, tc := range tests {
+		actual := UPSERTStmt("users", cols, 2, tc.conflict, tc.update, tc.dbtype)
+		if actual != tc.expected {
+			t.Errorf("%d: wrong stmt: %s", i, cmp.Diff(tc.expected, actual))
+		}
+	}
+
+	// Quoting disabled
+	opts := StmtOptions{DBType: PostgreSQL, NoQuoteIdentifiers: true}
+
+	expected := `INSERT INTO "Users" ( id,name,age ) VALUES ($1,$2,$3) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name RETURNING id`
+	actual := UPSERTStmtWithOptions(`"Users"`, cols, 1, []string{"id"}, []string{"name"}, opts) + " " + RETURNINGSuffixWithOptions([]string{"id"}, opts)
+	if actual != expected {
+		t.Errorf("wrong stmt: %s", cmp.Diff(expected, actual))
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name     string
+		dbtype   Database
+		expected string
+	}{
+		{"order", MySQL, "`order`"},
+		{"public.users", PostgreSQL, `"public"."users"`},
+		{`we"ird`, PostgreSQL, `"we""ird"`},
+		{"`already`", MySQL, "`already`"},
+		{"u.*", MySQL, "`u`.*"},
+	}
+
+	for _, tc := range tests {
+		if actual := QuoteIdent(tc.name, tc.dbtype); actual != tc.expected {
+			t.Errorf("wrong quoting of %s: %s", tc.name, actual)
+		}
+	}
+
+	stmt, _ := bulkInsertStmt("Users", []string{"Name"}, [][]interface{}{{"Brad"}}, BulkInsertOptions{DBType: PostgreSQL, NoQuoteIdentifiers: true})
+	if stmt != "INSERT INTO Users ( Name ) VALUES ( $1 )" {
+		t.Errorf("quoting not disabled: %s", stmt)
+	}
+}
+
+func TestBulkInsertQ(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type user struct {
+		ID   int    `dbq:"id,omitempty"`
+		Name string `dbq:"name"`
+	}
+
+	users := []user{{Name: "Brad"}, {Name: "Ange"}}
+
+	// PostgreSQL
+	mock.ExpectQuery(`^INSERT INTO "users" \( "name" \) VALUES \( \$1 \),\( \$2 \) RETURNING "id","name"$`).
+		WithArgs("Brad", "Ange").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Brad").AddRow(2, "Ange"))
+
+	opts := BulkInsertOptions{Table: "users", Returning: []string{"id", "name"}, DBType: PostgreSQL}
+	res := MustBulkInsertQ(context.Background(), db, users, opts, &Options{ConcreteStruct: user{}}).([]*user)
+
+	expected := []*user{{1, "Brad"}, {2, "Ange"}}
+	if !cmp.Equal(expected, res) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, res))
+	}
+
+	// MySQL
+	mock.ExpectExec("^INSERT INTO `users` \\( `name` \\) VALUES \\( \\? \\),\\( \\? \\)$").
+		WithArgs("Brad", "Ange").
+		WillReturnResult(sqlmock.NewResult(7, 2))
+	mock.ExpectQuery("^SELECT `id`,`name` FROM `users` WHERE `id` BETWEEN \\? AND \\? ORDER BY `id`$").
+		WithArgs(int64(7), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "Brad").AddRow(8, "Ange"))
+
+	opts.DBType = MySQL
+	res = MustBulkInsertQ(context.Background(), db, users, opts, &Options{ConcreteStruct: user{}}).([]*user)
+
+	expected = []*user{{7, "Brad"}, {8, "Ange"}}
+	if !cmp.Equal(expected, res) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, res))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTxOptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// Q falls back to db after the transaction is committed
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT 1$").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+
+	var qErr error
+	err = TxWithOptions(ctx, db, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) {
+		txCommit()
+		_, qErr = Q(ctx, "SELECT 1", nil)
+	}, &TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true})
+	if err != nil || qErr != nil {
+		t.Errorf("unexpected error: %v %v", err, qErr)
+	}
+
+	// NoPoolFallback
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	err = TxWithOptions(ctx, db, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) {
+		txCommit()
+		_, qErr = Q(ctx, "SELECT 1", nil)
+	}, &TxOptions{NoPoolFallback: true})
+	if err != nil || qErr != sql.ErrTxDone {
+		t.Errorf("expected sql.ErrTxDone: %v %v", err, qErr)
+	}
+
+	// MaxDuration
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var eErr error
+	err = TxWithOptions(ctx, db, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) {
+		time.Sleep(50 * time.Millisecond)
+		_, eErr = E(ctx, "UPDATE users SET age = 1", nil)
+		if eErr != nil {
+			return
+		}
+		txCommit()
+	}, &TxOptions{MaxDuration: 10 * time.Millisecond})
+	if err != nil || eErr == nil {
+		t.Errorf("expected transaction to be rolled back: %v %v", err, eErr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+type pqError struct {
+	Code string
+}
+
+func (e *pqError) Error() string { return "pq: " + e.Code }
+
+func (e *pqError) SQLState() string { return e.Code }
+
+
+func TestTxResult(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	insert := func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) (interface{}, error) {
+		res, err := E(ctx, "INSERT INTO users", nil)
+		if err != nil {
+			return nil, err
+		}
+		return res.LastInsertId()
+	}
+
+	opts := &TxOptions{RetryPolicy: ConstantDelayRetryPolicy(time.Millisecond, 3)}
+
+	// Serialization failures and deadlocks are retried
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users$").WillReturnError(&pqError{"40001"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users$").WillReturnError(xerrors.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1213}))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users$").WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectCommit()
+
+	id, err := TxResult(ctx, db, insert, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.(int64) != 7 {
+		t.Errorf("wrong result: %v", id)
+	}
+
+	// Other errors are not retried
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users$").WillReturnError(fmt.Errorf("insert failed"))
+	mock.ExpectRollback()
+
+	if _, err := TxResult(ctx, db, insert, opts); err == nil || err.Error() != "insert failed" {
+		t.Errorf("expected insert failed error: %v", err)
+	}
+
+	// Other MySQL errors are not retried by default
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users$").WillReturnError(&mysql.MySQLError{Number: 1205})
+	mock.ExpectRollback()
+
+	if _, err := TxResult(ctx, db, insert, opts); err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+
+	// Custom classifier
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users$").WillReturnError(xerrors.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1205}))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO users$").WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectCommit()
+
+	mysqlOpts := &TxOptions{
+		RetryPolicy: ConstantDelayRetryPolicy(time.Millisecond, 3),
+		IsRetryable: func(err error) bool {
+			var mErr *mysql.MySQLError
+			return xerrors.As(err, &mErr) && mErr.Number == 1205
+		},
+	}
+
+	id, err = TxResult(ctx, db, insert, mysqlOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.(int64) != 8 {
+		t.Errorf("wrong result: %v", id)
+	}
+
+	// Already committed
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	_, err = TxResult(ctx, db, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) (interface{}, error) {
+		return nil, txCommit()
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
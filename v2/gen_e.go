@@ -54,7 +54,7 @@ func E(ctx context.Context, db ExecContexter, query string, options *Options, ar
 		res, err = db.ExecContext(ctx, query, args...)
 		if err != nil {
 			if err == sql.ErrTxDone || err == sql.ErrConnDone || (strings.Contains(err.Error(), "sql: expected") && strings.Contains(err.Error(), "arguments, got")) {
-				return &backoff.PermanentError{err}
+				return &backoff.PermanentError{Err: err}
 			}
 			return err
 		}
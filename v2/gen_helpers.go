@@ -29,12 +29,101 @@ const (
 	PostgreSQL Database = 1
 )
 
+// QuoteIdent quotes a table or column name so that reserved words (eg. order) can be used safely.
+// Backticks are used for MySQL and double quotes are used for PostgreSQL. Each part of a schema-qualified name
+// is quoted separately. Names that are already quoted are returned unchanged.
+//
+// NOTE: PostgreSQL folds unquoted names to lower case, so quoted names with upper case letters (eg. "Users")
+// only match tables and columns that were also created using quotes.
+//
+// Example:
+//
+//  dbq.QuoteIdent("order")                          // Output: `order`
+//  dbq.QuoteIdent("public.users", dbq.PostgreSQL)   // Output: "public"."users"
+//
+func QuoteIdent(name string, dbtype ...Database) string {
+	if name == "" {
+		return name
+	}
+
+	q := "`"
+	if len(dbtype) > 0 && dbtype[0] == PostgreSQL {
+		q = `"`
+	}
+
+	if strings.HasPrefix(name, q) {
+
+		return name
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part == "*" {
+			continue
+		}
+		parts[i] = q + strings.Replace(part, q, q+q, -1) + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// QuoteIdents quotes each name using QuoteIdent.
+func QuoteIdents(names []string, dbtype ...Database) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		out = append(out, QuoteIdent(name, dbtype...))
+	}
+	return out
+}
+
+// QuoteIdentsIf quotes each name using QuoteIdent unless noQuote is true, in which case names are returned
+// unchanged. It is used to honour the NoQuoteIdentifiers option (eg. BulkInsertOptions.NoQuoteIdentifiers).
+func QuoteIdentsIf(names []string, dbtype Database, noQuote bool) []string {
+	if noQuote {
+		return names
+	}
+	return QuoteIdents(names, dbtype)
+}
+
+// StmtOptions is used to configure the statement builders (eg. INSERTStmtWithOptions).
+type StmtOptions struct {
+
+	// DBType sets the database being used. The default is MySQL.
+	DBType Database
+
+	// NoQuoteIdentifiers can be set to true to use the table and column names verbatim (eg. when they are
+	// already quoted). By default, they are quoted using QuoteIdent.
+	NoQuoteIdentifiers bool
+}
+
+// stmtOptions returns the StmtOptions used by the statement builders that only accept a dbtype.
+func stmtOptions(dbtype []Database) StmtOptions {
+	var opts StmtOptions
+	if len(dbtype) > 0 {
+		opts.DBType = dbtype[0]
+	}
+	return opts
+}
+
 // INSERTStmt will generate an INSERT statement. It can be used for bulk inserts.
+// The table and column names are quoted using QuoteIdent. See INSERTStmtWithOptions to disable quoting.
 //
-// NOTE: You may have to escape the column names. For MySQL, use backticks. Databases also have a limit
-// to the number of query placeholders you can have. This will limit the number of rows you can insert.
+// NOTE: Databases have a limit to the number of query placeholders you can have. This will limit the number of rows
+// you can insert. BulkInsertRows can be used to automatically split the rows into multiple statements.
 func INSERTStmt(tableName string, columns []string, rows int, dbtype ...Database) string {
-	return fmt.Sprintf("INSERT INTO %s ( %s ) VALUES %s", tableName, strings.Join(columns, ","), Ph(len(columns), rows, 0, dbtype...))
+	return INSERTStmtWithOptions(tableName, columns, rows, stmtOptions(dbtype))
+}
+
+// INSERTStmtWithOptions operates the same as INSERTStmt except the statement can be configured using opts.
+//
+// Example:
+//
+//  dbq.INSERTStmtWithOptions(`"Users"`, []string{"name"}, 1, dbq.StmtOptions{DBType: dbq.PostgreSQL, NoQuoteIdentifiers: true})
+//  // Output: INSERT INTO "Users" ( name ) VALUES ($1)
+//
+func INSERTStmtWithOptions(tableName string, columns []string, rows int, opts StmtOptions) string {
+	tableName = QuoteIdentsIf([]string{tableName}, opts.DBType, opts.NoQuoteIdentifiers)[0]
+	columns = QuoteIdentsIf(columns, opts.DBType, opts.NoQuoteIdentifiers)
+	return fmt.Sprintf("INSERT INTO %s ( %s ) VALUES %s", tableName, strings.Join(columns, ","), Ph(len(columns), rows, 0, opts.DBType))
 }
 
 // INSERT is the legacy equivalent of INSERTStmt.
@@ -44,6 +133,85 @@ func INSERT(tableName string, columns []string, rows int, dbtype ...Database) st
 	return INSERTStmt(tableName, columns, rows, dbtype...)
 }
 
+// UPSERTStmt will generate an INSERT statement that updates the existing row when a row with the same
+// primary key or unique key already exists. It can be used for bulk upserts.
+//
+// For PostgreSQL, conflictColumns sets the conflict target (eg. the primary key). It is required unless updateColumns
+// is empty. For MySQL, it is ignored since the conflict is detected using any primary key or unique index.
+//
+// updateColumns sets the columns that are updated with the new values. If it's empty, the existing row is left unchanged
+// (DO NOTHING).
+//
+// Example:
+//
+//  dbq.UPSERTStmt("users", []string{"id", "name", "age"}, 2, []string{"id"}, []string{"name", "age"})
+//  // Output: INSERT INTO `users` ( `id`,`name`,`age` ) VALUES ( ?,?,? ),( ?,?,? ) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`),`age` = VALUES(`age`)
+//
+//  dbq.UPSERTStmt("users", []string{"id", "name", "age"}, 2, []string{"id"}, []string{"name", "age"}, dbq.PostgreSQL)
+//  // Output: INSERT INTO "users" ( "id","name","age" ) VALUES ($1,$2,$3),($4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name","age" = EXCLUDED."age"
+//
+func UPSERTStmt(tableName string, columns []string, rows int, conflictColumns []string, updateColumns []string, dbtype ...Database) string {
+	return UPSERTStmtWithOptions(tableName, columns, rows, conflictColumns, updateColumns, stmtOptions(dbtype))
+}
+
+// UPSERTStmtWithOptions operates the same as UPSERTStmt except the statement can be configured using opts.
+func UPSERTStmtWithOptions(tableName string, columns []string, rows int, conflictColumns []string, updateColumns []string, opts StmtOptions) string {
+	return INSERTStmtWithOptions(tableName, columns, rows, opts) + " " + UPSERTSuffixWithOptions(columns, conflictColumns, updateColumns, opts)
+}
+
+// UPSERTSuffix generates the clause that UPSERTStmt appends to an INSERT statement. It can be used as the
+// StmtSuffix of BulkInsertOptions. columns is only used by MySQL to leave the existing row unchanged when
+// updateColumns is empty. The column names are quoted using QuoteIdent. See UPSERTSuffixWithOptions to
+// disable quoting.
+func UPSERTSuffix(columns []string, conflictColumns []string, updateColumns []string, dbtype ...Database) string {
+	return UPSERTSuffixWithOptions(columns, conflictColumns, updateColumns, stmtOptions(dbtype))
+}
+
+// UPSERTSuffixWithOptions operates the same as UPSERTSuffix except the clause can be configured using opts.
+func UPSERTSuffixWithOptions(columns []string, conflictColumns []string, updateColumns []string, opts StmtOptions) string {
+	typ := opts.DBType
+
+	columns = QuoteIdentsIf(columns, typ, opts.NoQuoteIdentifiers)
+	conflictColumns = QuoteIdentsIf(conflictColumns, typ, opts.NoQuoteIdentifiers)
+	updateColumns = QuoteIdentsIf(updateColumns, typ, opts.NoQuoteIdentifiers)
+
+	sets := make([]string, 0, len(updateColumns))
+
+	if typ == MySQL {
+		if len(updateColumns) == 0 {
+
+			col := columns[0]
+			if len(conflictColumns) > 0 {
+				col = conflictColumns[0]
+			}
+			return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", col, col)
+		}
+
+		for _, col := range updateColumns {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", col, col))
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ",")
+	}
+
+	var target string
+	if len(conflictColumns) > 0 {
+		target = "(" + strings.Join(conflictColumns, ",") + ") "
+	}
+
+	if len(updateColumns) == 0 {
+		return "ON CONFLICT " + target + "DO NOTHING"
+	}
+
+	if target == "" {
+		panic(errors.New("conflictColumns must not be empty"))
+	}
+
+	for _, col := range updateColumns {
+		sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+	}
+	return "ON CONFLICT " + target + "DO UPDATE SET " + strings.Join(sets, ",")
+}
+
 // Ph generates the placeholders for SQL queries.
 // For a bulk insert operation, nRows is the number of rows you intend
 // to insert, and nCols is the number of fields per row.
@@ -99,6 +267,7 @@ func Ph(nCols, nRows int, incr int, dbtype ...Database) string {
 }
 
 // FlattenArgs will accept a list of values and flatten any slices encountered.
+// Slices that implement driver.Valuer (eg. pq.Int64Array) and []byte are not flattened.
 //
 // Example:
 //
@@ -113,7 +282,7 @@ func FlattenArgs(args ...interface{}) []interface{} {
 
 	var sliceConv func(reflect.Value)
 	sliceConv = func(arg reflect.Value) {
-		if arg.Kind() == reflect.Slice {
+		if flattenable(arg) {
 			for i := 0; i < arg.Len(); i++ {
 				sliceConv(reflect.ValueOf(arg.Index(i).Interface()))
 			}
@@ -126,7 +295,7 @@ func FlattenArgs(args ...interface{}) []interface{} {
 
 	for i := range args {
 		arg := args[i]
-		if rarg := reflect.ValueOf(arg); rarg.Kind() == reflect.Slice {
+		if rarg := reflect.ValueOf(arg); flattenable(rarg) {
 			sliceConv(rarg)
 		} else {
 			out = append(out, arg)
@@ -136,6 +305,14 @@ func FlattenArgs(args ...interface{}) []interface{} {
 	return out
 }
 
+// flattenable reports whether FlattenArgs expands v into its elements.
+func flattenable(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice {
+		return false
+	}
+	return v.Type().Elem().Kind() != reflect.Uint8 && !v.Type().Implements(valuerType)
+}
+
 // ExponentialRetryPolicy is a retry policy with exponentially increasing intervals between
 // each retry attempt. If maxElapsedTime is 0, it will retry forever unless restricted by retryAttempts.
 //
@@ -217,8 +394,6 @@ func StdTimeConversionConfig(dbtype ...Database) *StructorConfig {
 			default:
 				return data, nil
 			}
-
-			return data, nil
 		},
 	}
 }
@@ -264,7 +439,7 @@ func Struct(strct interface{}, tagName ...string) []interface{} {
 			continue
 		}
 
-		if fieldTag == "-" || (strings.HasSuffix(fieldTag, ",omitempty") && reflect.DeepEqual(fieldVal, reflect.Zero(reflect.TypeOf(fieldVal)).Interface())) {
+		if _, opts := parseTag(fieldTag); fieldTag == "-" || (opts.Contains("omitempty") && reflect.DeepEqual(fieldVal, reflect.Zero(reflect.TypeOf(fieldVal)).Interface())) {
 			continue
 		}
 
@@ -279,6 +454,62 @@ func Struct(strct interface{}, tagName ...string) []interface{} {
 	return out
 }
 
+// StructColumns returns the column names of the fields of the struct. The names are in the same order as the
+// values returned by Struct, so they can be used to generate an INSERT statement. mapper is used to derive the
+// column name of fields that don't have one set in their struct tag. If mapper is nil, the field's name is used.
+// The function panics if strct is not an actual struct.
+//
+// NOTE: Struct flattens slice fields into multiple values, so they should be avoided.
+//
+// Example:
+//
+//  row := Row{"Brad", 45, time.Now()}
+//
+//  stmt := dbq.INSERTStmt("users", dbq.StructColumns(row, dbq.SnakeCase), 1)
+//  dbq.E(ctx, db, stmt, nil, dbq.Struct(row))
+//
+func StructColumns(strct interface{}, mapper NameMapper, tagName ...string) []string {
+
+	tg := "dbq"
+
+	if len(tagName) > 0 {
+		tg = tagName[0]
+	}
+
+	out := []string{}
+
+	if strct == nil {
+		panic(errors.New("strct must be a struct"))
+	}
+
+	s := reflect.Indirect(reflect.ValueOf(strct))
+	typeOfT := s.Type()
+
+	for i := 0; i < s.NumField(); i++ {
+		f := typeOfT.Field(i)
+
+		if f.PkgPath != "" {
+
+			continue
+		}
+
+		fieldValRaw := s.Field(i)
+
+		if fieldValRaw.Kind() == reflect.Map {
+			continue
+		}
+
+		name, opts, ok := fieldName(f, tg, mapper)
+		if !ok || (opts.Contains("omitempty") && reflect.DeepEqual(fieldValRaw.Interface(), reflect.Zero(f.Type).Interface())) {
+			continue
+		}
+
+		out = append(out, name)
+	}
+
+	return out
+}
+
 // Qs operates the same as Q except it requires you to provide a ConcreteStruct as an argument.
 // This allows you to recycle common options and conveniently provide a different ConcreteStruct.
 func Qs(ctx context.Context, db interface{}, query string, ConcreteStruct interface{}, options *Options, args ...interface{}) (out interface{}, rErr error) {
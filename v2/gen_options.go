@@ -54,9 +54,17 @@ var SingleResult = &Options{SingleResult: true}
 type Options struct {
 
 	// ConcreteStruct can be set to any concrete struct (not a pointer).
-	// When set, the mapstructure package is used to convert the returned
-	// results automatically from a map to a struct. The `dbq` struct tag
-	// can be used to map column names to the struct's fields.
+	// When set, the returned results are automatically converted to the struct.
+	// The `dbq` struct tag can be used to map column names to the struct's fields.
+	// The values are scanned directly into the fields using a plan that is computed once
+	// and cached. The mapstructure package is only used when a DecoderConfig is provided,
+	// or a field's type is not supported by the plan. Fields that implement sql.Scanner
+	// (eg. sql.NullString) or encoding.TextUnmarshaler are populated by their own methods.
+	//
+	// Nested structs (and pointers to structs) are populated from columns named with the parent
+	// field's name followed by a dot (eg. address.city). The prefix can be customized using the
+	// prefix tag option (eg. `dbq:"address,prefix=addr_"`). Fields of embedded structs are promoted.
+	// A pointer to a nested struct remains nil when all its columns are NULL.
 	//
 	// See: https://godoc.org/github.com/mitchellh/mapstructure
 	ConcreteStruct interface{}
@@ -69,6 +77,59 @@ type Options struct {
 	// See: https://godoc.org/github.com/mitchellh/mapstructure
 	DecoderConfig *StructorConfig
 
+	// NameMapper is used to derive the column name of ConcreteStruct's fields that don't
+	// have a `dbq` struct tag. If it's not supplied, the field's name is used (case-insensitive).
+	// See NameMapper for how the mapping is cached.
+	//
+	// Example:
+	//
+	//  dbq.SnakeCase // CreatedAt field maps to created_at column
+	//
+	NameMapper NameMapper
+
+	// Aggregate can be set to true to group the rows returned by a JOIN into their parent structs. Rows are
+	// grouped by the ConcreteStruct's primary key field(s), which must be tagged with the pk option
+	// (eg. `dbq:"id,pk"`). Slice fields of nested structs are populated with the children from each
+	// row. Children with a primary key are also grouped, which allows for multiple levels of nesting.
+	// The order of the rows is preserved and PostUnmarshaler is only called for the aggregated results.
+	//
+	// Example:
+	//
+	//  type item struct {
+	//     ID   int    `dbq:"id,pk"`
+	//     Name string `dbq:"name"`
+	//  }
+	//
+	//  type order struct {
+	//     ID    int     `dbq:"id,pk"`
+	//     Items []*item `dbq:"items"` // items.id, items.name
+	//  }
+	//
+	Aggregate bool
+
+	// KeyBy can be set to a column name to return the results as a map keyed by the column's value
+	// instead of a slice. For ConcreteStruct, a map[K]*struct is returned where K is the type of the field
+	// that the column maps to. Otherwise, a map[interface{}]map[string]interface{} is returned where
+	// pointer values are dereferenced. Rows where the key is NULL are excluded. SingleResult is ignored.
+	//
+	// When more than 1 row has the same key, the last row is kept unless ErrOnDuplicateKey is set.
+	//
+	// Example:
+	//
+	//  opts := &dbq.Options{ConcreteStruct: user{}, KeyBy: "id"}
+	//  users := dbq.MustQ(ctx, db, "SELECT * FROM users", opts).(map[int]*user)
+	//
+	KeyBy string
+
+	// GroupByKey can be set to true (along with KeyBy) to group all the rows with the same key.
+	// A map[K][]*struct (or map[interface{}][]map[string]interface{}) is returned instead.
+	// The order of the rows is preserved within each group.
+	GroupByKey bool
+
+	// ErrOnDuplicateKey can be set to true (along with KeyBy) to return an error wrapping ErrDuplicateKey
+	// when more than 1 row has the same key.
+	ErrOnDuplicateKey bool
+
 	// SingleResult can be set to true if you know the query will return at most 1 result.
 	// When true, a nil is returned if no result is found. Alternatively, it will return the
 	// single result directly (instead of wrapped in a slice). This makes it easier to
@@ -118,3 +179,39 @@ func (o *Options) Qs(ctx context.Context, db interface{}, query string, Concrete
 func (o *Options) MustQs(ctx context.Context, db interface{}, query string, ConcreteStruct interface{}, args ...interface{}) interface{} {
 	return MustQs(ctx, db, query, ConcreteStruct, o, args...)
 }
+
+// QR is a convenience function that calls dbq.QR.
+// It allows you to recycle common options.
+func (o *Options) QR(ctx context.Context, db interface{}, query string, args ...interface{}) (*ResultSet, error) {
+	return QR(ctx, db, query, o, args...)
+}
+
+// MustQR is a convenience function that calls dbq.MustQR.
+// It allows you to recycle common options.
+func (o *Options) MustQR(ctx context.Context, db interface{}, query string, args ...interface{}) *ResultSet {
+	return MustQR(ctx, db, query, o, args...)
+}
+
+// QScalar is a convenience function that calls dbq.QScalar.
+// It allows you to recycle common options.
+func (o *Options) QScalar(ctx context.Context, db interface{}, dest interface{}, query string, args ...interface{}) error {
+	return QScalar(ctx, db, dest, query, o, args...)
+}
+
+// QColumn is a convenience function that calls dbq.QColumn.
+// It allows you to recycle common options.
+func (o *Options) QColumn(ctx context.Context, db interface{}, dest interface{}, query string, args ...interface{}) error {
+	return QColumn(ctx, db, dest, query, o, args...)
+}
+
+// QExists is a convenience function that calls dbq.QExists.
+// It allows you to recycle common options.
+func (o *Options) QExists(ctx context.Context, db interface{}, query string, args ...interface{}) (bool, error) {
+	return QExists(ctx, db, query, o, args...)
+}
+
+// QCount is a convenience function that calls dbq.QCount.
+// It allows you to recycle common options.
+func (o *Options) QCount(ctx context.Context, db interface{}, query string, args ...interface{}) (int64, error) {
+	return QCount(ctx, db, query, o, args...)
+}
@@ -36,14 +36,15 @@ func MustQ(ctx context.Context, db interface{}, query string, options *Options,
 // Q is used for querying a SQL database. A []map[string]interface{} is ordinarily returned.
 // Each returned row (an item in the slice) contains a map where the keys are the columns, and
 // the values are the data for each column.
-// However, when a ConcreteStruct is provided via the options, the mapstructure package is used to automatically
-// return []*struct instead. To bypass the mapstructure package, ScanFaster interface can be implemented.
+// However, when a ConcreteStruct is provided via the options, []*struct is automatically returned instead.
+// The results are scanned directly into the struct's fields, unless a DecoderConfig is provided, in which case
+// the mapstructure package is used. The ScanFaster interface can be implemented to bypass reflection altogether.
 //
 // args is a list of values to replace the placeholders in the query. When an arg is a slice, the values of the slice
 // will automatically be flattened to a list of interface{}.
 //
 // NOTE: sql.ErrNoRows is never returned as an error: A slice is always returned, unless the
-// behavior is modified by the SingleResult Option.
+// behavior is modified by the SingleResult or KeyBy Options.
 func Q(ctx context.Context, db interface{}, query string, options *Options, args ...interface{}) (out interface{}, rErr error) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -59,7 +60,9 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 	}
 
 	defer func() {
-		if rErr == nil && o.SingleResult {
+		if rErr == nil && o.KeyBy != "" {
+			out, rErr = keyResults(out, o)
+		} else if rErr == nil && o.SingleResult {
 			rows := reflect.ValueOf(out)
 			if rows.Len() == 0 {
 				out = nil
@@ -94,52 +97,7 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 		outStruct = reflect.MakeSlice(typ, 0, 0)
 	}
 
-	var (
-		rows      rows
-		err       error
-		operation func() error
-	)
-
-	if o.RetryPolicy == nil {
-		switch db := db.(type) {
-		case QueryContexter:
-			rows, err = db.QueryContext(ctx, query, args...)
-		case queryContexter2:
-			rows, err = db.QueryContext(ctx, query, args...)
-		default:
-			panic(fmt.Sprintf("interface conversion: %T is not dbq.QueryContexter: missing method: QueryContext", db))
-		}
-	} else {
-		switch db := db.(type) {
-		case QueryContexter:
-			operation = func() error {
-				rows, err = db.QueryContext(ctx, query, args...)
-				if err != nil {
-					if err == sql.ErrTxDone || err == sql.ErrConnDone || (strings.Contains(err.Error(), "sql: expected") && strings.Contains(err.Error(), "arguments, got")) {
-						return &backoff.PermanentError{err}
-					}
-					return err
-				}
-				return nil
-			}
-		case queryContexter2:
-			operation = func() error {
-				rows, err = db.QueryContext(ctx, query, args...)
-				if err != nil {
-					if err == sql.ErrTxDone || err == sql.ErrConnDone || (strings.Contains(err.Error(), "sql: expected") && strings.Contains(err.Error(), "arguments, got")) {
-						return &backoff.PermanentError{err}
-					}
-					return err
-				}
-				return nil
-			}
-		default:
-			panic(fmt.Sprintf("interface conversion: %T is not dbq.QueryContexter: missing method: QueryContext", db))
-		}
-
-		err = backoff.Retry(operation, o.RetryPolicy)
-	}
-
+	rows, err := queryRows(ctx, db, query, o.RetryPolicy, args)
 	if err != nil {
 		return nil, err
 	}
@@ -151,6 +109,26 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 	}
 	totalColumns := len(cols)
 
+	var (
+		keys    [][]decodeKey
+		plan    *scanPlan
+		current reflect.Value
+		dests   []interface{}
+	)
+
+	if o.ConcreteStruct != nil && !scanFast {
+
+		if o.DecoderConfig == nil {
+			plan = getScanPlan(reflect.TypeOf(o.ConcreteStruct), cols, o.NameMapper)
+		}
+
+		if plan == nil {
+			keys = decodeKeys(reflect.TypeOf(o.ConcreteStruct), cols, o.NameMapper)
+		} else {
+			dests = plan.dests(&current)
+		}
+	}
+
 	for rows.Next() {
 		var rowData []interface{}
 
@@ -161,6 +139,14 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 			}
 			outStruct = reflect.Append(outStruct.(reflect.Value), reflect.ValueOf(res))
 			continue
+		} else if plan != nil {
+			res := reflect.New(reflect.TypeOf(o.ConcreteStruct))
+			current = res.Elem()
+			if err := rows.Scan(dests...); err != nil {
+				return nil, err
+			}
+			outStruct = reflect.Append(outStruct.(reflect.Value), res)
+			continue
 		} else {
 			rowData = make([]interface{}, totalColumns)
 			for i := range rowData {
@@ -174,19 +160,22 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 		vals := map[string]interface{}{}
 		if o.ConcreteStruct != nil {
 			for colID, elem := range rowData {
-				fieldName := cols[colID].Name()
 				raw := elem.(*sql.RawBytes)
 				if *raw == nil {
-					vals[fieldName] = nil
+					setDecodeValue(vals, keys[colID], nil)
 				} else {
-					vals[fieldName] = string(*raw)
+					setDecodeValue(vals, keys[colID], string(*raw))
 				}
 			}
 
 			res := reflect.New(reflect.TypeOf(o.ConcreteStruct)).Interface()
 			if o.DecoderConfig != nil {
+				var hook mapstructure.DecodeHookFunc = scannerDecodeHook
+				if o.DecoderConfig.DecodeHook != nil {
+					hook = mapstructure.ComposeDecodeHookFunc(o.DecoderConfig.DecodeHook, scannerDecodeHook)
+				}
 				dc := &mapstructure.DecoderConfig{
-					DecodeHook:       o.DecoderConfig.DecodeHook,
+					DecodeHook:       hook,
 					ZeroFields:       true,
 					TagName:          "dbq",
 					WeaklyTypedInput: o.DecoderConfig.WeaklyTypedInput,
@@ -202,6 +191,7 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 				}
 			} else {
 				dc := &mapstructure.DecoderConfig{
+					DecodeHook:       scannerDecodeHook,
 					ZeroFields:       true,
 					TagName:          "dbq",
 					WeaklyTypedInput: true,
@@ -231,289 +221,7 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 				continue
 			}
 
-			colType := cols[colID].DatabaseTypeName()
-			nullable, hasNullableInfo := cols[colID].Nullable()
-
-			var val *string
-
-			if *raw != nil {
-				val = &[]string{string(*raw)}[0]
-			}
-
-			switch colType {
-			case "NULL":
-				vals[fieldName] = nil
-			case "CHAR", "VARCHAR", "TEXT", "NVARCHAR", "MEDIUMTEXT", "LONGTEXT":
-				if nullable || !hasNullableInfo {
-					vals[fieldName] = val
-				} else {
-					if hasNullableInfo {
-
-						vals[fieldName] = *val
-					}
-				}
-			case "FLOAT", "DOUBLE", "DECIMAL", "NUMERIC", "FLOAT4", "FLOAT8":
-				if nullable || !hasNullableInfo {
-					if val == nil {
-						vals[fieldName] = (*float64)(nil)
-					} else {
-						f, _ := strconv.ParseFloat(*val, 64)
-						vals[fieldName] = &f
-					}
-				} else {
-					if hasNullableInfo {
-
-						f, _ := strconv.ParseFloat(*val, 64)
-						vals[fieldName] = f
-					}
-				}
-			case "INT", "TINYINT", "INT2", "INT4", "INT8", "MEDIUMINT", "SMALLINT", "BIGINT":
-
-				switch cols[colID].ScanType().Kind() {
-				case reflect.Uint:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*uint)(nil)
-						} else {
-							vals[fieldName] = parseUintP(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseUint(*val)
-						}
-					}
-				case reflect.Uint8:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*uint8)(nil)
-						} else {
-							vals[fieldName] = parseUint8P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseUint8(*val)
-						}
-					}
-				case reflect.Uint16:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*uint16)(nil)
-						} else {
-							vals[fieldName] = parseUint16P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseUint16(*val)
-						}
-					}
-				case reflect.Uint32:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*uint32)(nil)
-						} else {
-							vals[fieldName] = parseUint32P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseUint32(*val)
-						}
-					}
-				case reflect.Uint64:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*uint64)(nil)
-						} else {
-							vals[fieldName] = parseUint64P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseUint64(*val)
-						}
-					}
-				case reflect.Int:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*int)(nil)
-						} else {
-							vals[fieldName] = parseIntP(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseInt(*val)
-						}
-					}
-				case reflect.Int8:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*int8)(nil)
-						} else {
-							vals[fieldName] = parseInt8P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseInt8(*val)
-						}
-					}
-				case reflect.Int16:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*int16)(nil)
-						} else {
-							vals[fieldName] = parseInt16P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseInt16(*val)
-						}
-					}
-				case reflect.Int32:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*int32)(nil)
-						} else {
-							vals[fieldName] = parseInt32P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseInt32(*val)
-						}
-					}
-				case reflect.Int64:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*int64)(nil)
-						} else {
-							vals[fieldName] = parseInt64P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseInt64(*val)
-						}
-					}
-				default:
-					if nullable || !hasNullableInfo {
-						if val == nil {
-							vals[fieldName] = (*int64)(nil)
-						} else {
-							vals[fieldName] = parseInt64P(*val)
-						}
-					} else {
-						if hasNullableInfo {
-
-							vals[fieldName] = parseInt64(*val)
-						}
-					}
-				}
-			case "BOOL":
-				if nullable || !hasNullableInfo {
-					if val == nil {
-						vals[fieldName] = (*bool)(nil)
-					} else {
-						if *val == "true" || *val == "TRUE" || *val == "1" {
-							vals[fieldName] = &[]bool{true}[0]
-						} else {
-							vals[fieldName] = &[]bool{false}[0]
-						}
-					}
-				} else {
-					if hasNullableInfo {
-
-						if *val == "true" || *val == "TRUE" || *val == "1" {
-							vals[fieldName] = true
-						} else {
-							vals[fieldName] = false
-						}
-					}
-				}
-			case "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
-				if nullable || !hasNullableInfo {
-					if val == nil {
-						vals[fieldName] = (*time.Time)(nil)
-					} else {
-						t, err := time.Parse("2006-01-02 15:04:05", *val)
-						if err != nil {
-							t, _ = time.Parse(time.RFC3339, *val)
-						}
-						vals[fieldName] = &t
-					}
-				} else {
-					if hasNullableInfo {
-
-						t, err := time.Parse("2006-01-02 15:04:05", *val)
-						if err != nil {
-							t, _ = time.Parse(time.RFC3339, *val)
-						}
-						vals[fieldName] = &t
-					}
-				}
-			case "JSON", "JSONB":
-				if val == nil {
-					vals[fieldName] = nil
-				} else {
-					var jData interface{}
-					json.Unmarshal(*raw, &jData)
-					vals[fieldName] = jData
-				}
-			case "DATE":
-				if nullable || !hasNullableInfo {
-					if val == nil {
-						vals[fieldName] = (*civil.Date)(nil)
-					} else {
-						d, err := civil.ParseDate(*val)
-						if err != nil {
-							t, _ := time.Parse(time.RFC3339, *val)
-							d = civil.Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
-						}
-						vals[fieldName] = &d
-					}
-				} else {
-					if hasNullableInfo {
-
-						d, err := civil.ParseDate(*val)
-						if err != nil {
-							t, _ := time.Parse(time.RFC3339, *val)
-							d = civil.Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
-						}
-						vals[fieldName] = d
-					}
-				}
-			case "TIME":
-				if nullable || !hasNullableInfo {
-					if val == nil {
-						vals[fieldName] = (*civil.Time)(nil)
-					} else {
-						t, _ := civil.ParseTime(*val)
-						vals[fieldName] = &t
-					}
-				} else {
-					if hasNullableInfo {
-
-						t, _ := civil.ParseTime(*val)
-						vals[fieldName] = t
-					}
-				}
-
-			default:
-
-				if nullable || !hasNullableInfo {
-					vals[fieldName] = val
-				} else {
-					if hasNullableInfo {
-
-						vals[fieldName] = *val
-					}
-				}
-			}
+			vals[fieldName] = decodeColumn(cols[colID], *raw)
 		}
 		outMap = append(outMap, vals)
 	}
@@ -527,6 +235,13 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 		return nil, err
 	}
 
+	if o.ConcreteStruct != nil && o.Aggregate {
+		outStruct, err = aggregate(outStruct.(reflect.Value))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if o.PostFetch != nil {
 		err := o.PostFetch(ctx)
 		if err != nil {
@@ -581,3 +296,342 @@ func Q(ctx context.Context, db interface{}, query string, options *Options, args
 
 	return outMap, nil
 }
+
+// queryRows executes the query and returns the rows. The query is retried according
+// to retryPolicy (if provided).
+func queryRows(ctx context.Context, db interface{}, query string, retryPolicy backoff.BackOff, args []interface{}) (rows rows, err error) {
+	var operation func() error
+
+	if retryPolicy == nil {
+		switch db := db.(type) {
+		case QueryContexter:
+			rows, err = db.QueryContext(ctx, query, args...)
+		case queryContexter2:
+			rows, err = db.QueryContext(ctx, query, args...)
+		default:
+			panic(fmt.Sprintf("interface conversion: %T is not dbq.QueryContexter: missing method: QueryContext", db))
+		}
+	} else {
+		switch db := db.(type) {
+		case QueryContexter:
+			operation = func() error {
+				rows, err = db.QueryContext(ctx, query, args...)
+				if err != nil {
+					if err == sql.ErrTxDone || err == sql.ErrConnDone || (strings.Contains(err.Error(), "sql: expected") && strings.Contains(err.Error(), "arguments, got")) {
+						return &backoff.PermanentError{Err: err}
+					}
+					return err
+				}
+				return nil
+			}
+		case queryContexter2:
+			operation = func() error {
+				rows, err = db.QueryContext(ctx, query, args...)
+				if err != nil {
+					if err == sql.ErrTxDone || err == sql.ErrConnDone || (strings.Contains(err.Error(), "sql: expected") && strings.Contains(err.Error(), "arguments, got")) {
+						return &backoff.PermanentError{Err: err}
+					}
+					return err
+				}
+				return nil
+			}
+		default:
+			panic(fmt.Sprintf("interface conversion: %T is not dbq.QueryContexter: missing method: QueryContext", db))
+		}
+
+		err = backoff.Retry(operation, retryPolicy)
+	}
+
+	return rows, err
+}
+
+// decodeColumn converts the raw value of a column into the type that best represents
+// the column's database type. It is used when results are returned as a map.
+func decodeColumn(col *sql.ColumnType, raw sql.RawBytes) interface{} {
+	var out interface{}
+
+	colType := col.DatabaseTypeName()
+	nullable, hasNullableInfo := col.Nullable()
+
+	var val *string
+
+	if raw != nil {
+		val = &[]string{string(raw)}[0]
+	}
+
+	switch colType {
+	case "NULL":
+		out = nil
+	case "CHAR", "VARCHAR", "TEXT", "NVARCHAR", "MEDIUMTEXT", "LONGTEXT":
+		if nullable || !hasNullableInfo {
+			out = val
+		} else {
+			if hasNullableInfo {
+
+				out = *val
+			}
+		}
+	case "FLOAT", "DOUBLE", "DECIMAL", "NUMERIC", "FLOAT4", "FLOAT8":
+		if nullable || !hasNullableInfo {
+			if val == nil {
+				out = (*float64)(nil)
+			} else {
+				f, _ := strconv.ParseFloat(*val, 64)
+				out = &f
+			}
+		} else {
+			if hasNullableInfo {
+
+				f, _ := strconv.ParseFloat(*val, 64)
+				out = f
+			}
+		}
+	case "INT", "TINYINT", "INT2", "INT4", "INT8", "MEDIUMINT", "SMALLINT", "BIGINT":
+
+		switch col.ScanType().Kind() {
+		case reflect.Uint:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*uint)(nil)
+				} else {
+					out = parseUintP(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseUint(*val)
+				}
+			}
+		case reflect.Uint8:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*uint8)(nil)
+				} else {
+					out = parseUint8P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseUint8(*val)
+				}
+			}
+		case reflect.Uint16:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*uint16)(nil)
+				} else {
+					out = parseUint16P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseUint16(*val)
+				}
+			}
+		case reflect.Uint32:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*uint32)(nil)
+				} else {
+					out = parseUint32P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseUint32(*val)
+				}
+			}
+		case reflect.Uint64:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*uint64)(nil)
+				} else {
+					out = parseUint64P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseUint64(*val)
+				}
+			}
+		case reflect.Int:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*int)(nil)
+				} else {
+					out = parseIntP(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseInt(*val)
+				}
+			}
+		case reflect.Int8:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*int8)(nil)
+				} else {
+					out = parseInt8P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseInt8(*val)
+				}
+			}
+		case reflect.Int16:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*int16)(nil)
+				} else {
+					out = parseInt16P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseInt16(*val)
+				}
+			}
+		case reflect.Int32:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*int32)(nil)
+				} else {
+					out = parseInt32P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseInt32(*val)
+				}
+			}
+		case reflect.Int64:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*int64)(nil)
+				} else {
+					out = parseInt64P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseInt64(*val)
+				}
+			}
+		default:
+			if nullable || !hasNullableInfo {
+				if val == nil {
+					out = (*int64)(nil)
+				} else {
+					out = parseInt64P(*val)
+				}
+			} else {
+				if hasNullableInfo {
+
+					out = parseInt64(*val)
+				}
+			}
+		}
+	case "BOOL":
+		if nullable || !hasNullableInfo {
+			if val == nil {
+				out = (*bool)(nil)
+			} else {
+				if *val == "true" || *val == "TRUE" || *val == "1" {
+					out = &[]bool{true}[0]
+				} else {
+					out = &[]bool{false}[0]
+				}
+			}
+		} else {
+			if hasNullableInfo {
+
+				if *val == "true" || *val == "TRUE" || *val == "1" {
+					out = true
+				} else {
+					out = false
+				}
+			}
+		}
+	case "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+		if nullable || !hasNullableInfo {
+			if val == nil {
+				out = (*time.Time)(nil)
+			} else {
+				t, err := time.Parse("2006-01-02 15:04:05", *val)
+				if err != nil {
+					t, _ = time.Parse(time.RFC3339, *val)
+				}
+				out = &t
+			}
+		} else {
+			if hasNullableInfo {
+
+				t, err := time.Parse("2006-01-02 15:04:05", *val)
+				if err != nil {
+					t, _ = time.Parse(time.RFC3339, *val)
+				}
+				out = &t
+			}
+		}
+	case "JSON", "JSONB":
+		if val == nil {
+			out = nil
+		} else {
+			var jData interface{}
+			json.Unmarshal(raw, &jData)
+			out = jData
+		}
+	case "DATE":
+		if nullable || !hasNullableInfo {
+			if val == nil {
+				out = (*civil.Date)(nil)
+			} else {
+				d, err := civil.ParseDate(*val)
+				if err != nil {
+					t, _ := time.Parse(time.RFC3339, *val)
+					d = civil.Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+				}
+				out = &d
+			}
+		} else {
+			if hasNullableInfo {
+
+				d, err := civil.ParseDate(*val)
+				if err != nil {
+					t, _ := time.Parse(time.RFC3339, *val)
+					d = civil.Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+				}
+				out = d
+			}
+		}
+	case "TIME":
+		if nullable || !hasNullableInfo {
+			if val == nil {
+				out = (*civil.Time)(nil)
+			} else {
+				t, _ := civil.ParseTime(*val)
+				out = &t
+			}
+		} else {
+			if hasNullableInfo {
+
+				t, _ := civil.ParseTime(*val)
+				out = t
+			}
+		}
+
+	default:
+		if nullable || !hasNullableInfo {
+			out = val
+		} else {
+			if hasNullableInfo {
+
+				out = *val
+			}
+		}
+	}
+
+	return out
+}
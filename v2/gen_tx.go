@@ -8,11 +8,14 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	rlSql "github.com/rocketlaunchr/mysql-go"
 	// "gopkg.in/cenkalti/backoff.v4"
 	"github.com/cenkalti/backoff/v4"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/xerrors"
 )
 
 type txer interface {
@@ -20,6 +23,30 @@ type txer interface {
 	Rollback() error
 }
 
+// savepoints is used to generate a unique name for each savepoint.
+var savepoints uint64
+
+// savepointTx is used when Tx is nested inside an existing transaction.
+// Committing releases the savepoint and rolling back rolls back to the savepoint before releasing it.
+type savepointTx struct {
+	ctx  context.Context
+	tx   ExecContexter
+	name string
+}
+
+func (s savepointTx) Commit() error {
+	_, err := s.tx.ExecContext(s.ctx, "RELEASE SAVEPOINT "+s.name)
+	return err
+}
+
+func (s savepointTx) Rollback() error {
+	if _, err := s.tx.ExecContext(s.ctx, "ROLLBACK TO SAVEPOINT "+s.name); err != nil {
+		return err
+	}
+	_, err := s.tx.ExecContext(s.ctx, "RELEASE SAVEPOINT "+s.name)
+	return err
+}
+
 // QFn is shorthand for Q. It will automatically use the appropriate transaction.
 type QFn func(ctx context.Context, query string, options *Options, args ...interface{}) (interface{}, error)
 
@@ -33,6 +60,10 @@ type TxCommit func() error
 // The transaction is automatically rolled back unless explicitly committed by calling txCommit.
 // tx is only exposed for performance purposes. Do not use it to commit or rollback.
 //
+// If db is already a transaction, a savepoint is created instead. Calling txCommit releases the savepoint and the
+// automatic rollback only rolls back to (and releases) the savepoint, leaving the outer transaction intact. This allows Tx to be
+// nested for both MySQL and PostgreSQL.
+//
 // NOTE: Until this note is removed, this function is not necessarily backward compatible.
 //
 // Example:
@@ -50,43 +81,123 @@ type TxCommit func() error
 //  })
 //
 func Tx(ctx context.Context, db interface{}, fn func(tx interface{}, Q QFn, E EFn, txCommit TxCommit), retryPolicy ...backoff.BackOff) error {
+	opts := &TxOptions{}
+	if len(retryPolicy) > 0 {
+		opts.RetryPolicy = retryPolicy[0]
+	}
+	return TxWithOptions(ctx, db, fn, opts)
+}
+
+// TxOptions is used to configure TxWithOptions.
+type TxOptions struct {
+
+	// Isolation sets the transaction isolation level. The default is the driver's default level.
+	// It is ignored when db is already a transaction.
+	Isolation sql.IsolationLevel
+
+	// ReadOnly can be set to true to start a read-only transaction.
+	// It is ignored when db is already a transaction.
+	ReadOnly bool
+
+	// MaxDuration sets the maximum duration of the transaction. The transaction is rolled back when it
+	// is exceeded. The default is no limit. It is ignored when db is already a transaction.
+	MaxDuration time.Duration
+
+	// RetryPolicy can be set if you want to retry fn in the event that the transaction is rolled back.
+	RetryPolicy backoff.BackOff
+
+	// IsRetryable reports whether TxResult should retry fn after it failed with err. The default retries
+	// serialization failures and deadlocks: errors with a SQLState() string method reporting 40001 or 40P01
+	// (eg. github.com/jackc/pgx) and github.com/go-sql-driver/mysql errors numbered 1213.
+	IsRetryable func(err error) bool
+
+	// RollbackRetryPolicy sets how a failed rollback is retried. The default is an exponential backoff
+	// for up to 120 seconds.
+	RollbackRetryPolicy backoff.BackOff
+
+	// NoPoolFallback can be set to true to prevent Q (QFn) from querying db directly once the transaction
+	// has been committed or rolled back (sql.ErrTxDone).
+	NoPoolFallback bool
+}
+
+// TxWithOptions operates the same as Tx except the transaction can be configured using opts.
+//
+// Example:
+//
+//  opts := &dbq.TxOptions{Isolation: sql.LevelSerializable, MaxDuration: 10 * time.Second}
+//
+//  dbq.TxWithOptions(ctx, pool, func(tx interface{}, Q dbq.QFn, E dbq.EFn, txCommit dbq.TxCommit) {
+//     ...
+//  }, opts)
+//
+func TxWithOptions(ctx context.Context, db interface{}, fn func(tx interface{}, Q QFn, E EFn, txCommit TxCommit), opts *TxOptions) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+
+	rollbackCtx := ctx
+
+	if opts.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxDuration)
+		defer cancel()
+	}
+
+	var sqlOpts *sql.TxOptions
+	if opts.Isolation != sql.LevelDefault || opts.ReadOnly {
+		sqlOpts = &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+	}
+
 	var (
 		alreadyTx bool
 		tx        interface{}
+		txr       txer
 		err       error
 	)
 
 	switch db := db.(type) {
 	case BeginTxer:
-		tx, err = db.BeginTx(ctx, nil)
+		tx, err = db.BeginTx(ctx, sqlOpts)
 		if err != nil {
 			return err
 		}
 	case beginTxer2:
-		tx, err = db.BeginTx(ctx, nil)
+		tx, err = db.BeginTx(ctx, sqlOpts)
 		if err != nil {
 			return err
 		}
 	case *sql.Tx, *rlSql.Tx:
 		tx = db
 		alreadyTx = true
+
+		sp := savepointTx{ctx: rollbackCtx, tx: db.(ExecContexter), name: fmt.Sprintf("dbq_sp_%d", atomic.AddUint64(&savepoints, 1))}
+		_, err = sp.tx.ExecContext(ctx, "SAVEPOINT "+sp.name)
+		if err != nil {
+			return err
+		}
+		txr = sp
 	default:
 		panic(fmt.Sprintf("interface conversion: %T is not dbq.BeginTxer: missing method: BeginTx", db))
 	}
 
+	if txr == nil {
+		txr = tx.(txer)
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
-			tx.(txer).Rollback()
+			txr.Rollback()
 			panic(r)
 		}
 	}()
 
 	qFn := func(ctx context.Context, query string, options *Options, args ...interface{}) (interface{}, error) {
 		res, err := Q(ctx, tx, query, options, args...)
-		if err == sql.ErrTxDone && !alreadyTx {
+		if err == sql.ErrTxDone && !alreadyTx && !opts.NoPoolFallback {
 			return Q(ctx, db, query, options, args...)
 		}
 		return res, err
@@ -98,7 +209,10 @@ func Tx(ctx context.Context, db interface{}, fn func(tx interface{}, Q QFn, E EF
 
 	completed := false
 	txCommit := func() error {
-		err := tx.(txer).Commit()
+		if completed {
+			return nil
+		}
+		err := txr.Commit()
 		if err == nil || err == sql.ErrTxDone {
 			completed = true
 			return nil
@@ -113,25 +227,130 @@ func Tx(ctx context.Context, db interface{}, fn func(tx interface{}, Q QFn, E EF
 		}
 
 		op2 := func() error {
-			err = tx.(txer).Rollback()
+			err = txr.Rollback()
 			if err == sql.ErrTxDone {
 				return nil
 			}
 			return err
 		}
 
-		exp := ExponentialRetryPolicy(120 * time.Second)
-		err := backoff.Retry(op2, backoff.WithContext(exp, ctx))
+		rollbackPolicy := opts.RollbackRetryPolicy
+		if rollbackPolicy == nil {
+			rollbackPolicy = ExponentialRetryPolicy(120 * time.Second)
+		}
+		err := backoff.Retry(op2, backoff.WithContext(rollbackPolicy, rollbackCtx))
 		if err != nil {
-			return &backoff.PermanentError{err}
+			return &backoff.PermanentError{Err: err}
 		}
 		return nil
 	}
 
-	if !(len(retryPolicy) > 0 && retryPolicy[0] != nil) {
+	if opts.RetryPolicy == nil {
 
 		return operation()
 	}
 
-	return backoff.Retry(operation, backoff.WithContext(retryPolicy[0], ctx))
+	return backoff.Retry(operation, backoff.WithContext(opts.RetryPolicy, ctx))
+}
+
+// TxResult is used to perform an arbitrarily complex operation inside a transaction and return a result.
+// The transaction is rolled back if fn returns an error. Otherwise, it is committed (unless fn has already
+// called txCommit). The result returned by fn is returned.
+//
+// When the database reports a serialization failure or a deadlock (see opts.IsRetryable), the transaction is rolled
+// back and fn is called again in a new transaction according to opts.RetryPolicy. The default is to retry up to 3 times
+// with exponentially increasing intervals. Other errors are not retried. If db is already a transaction, fn is not
+// retried since the outer transaction must be retried instead.
+//
+// Example:
+//
+//  id, err := dbq.TxResult(ctx, pool, func(tx interface{}, Q dbq.QFn, E dbq.EFn, txCommit dbq.TxCommit) (interface{}, error) {
+//     res, err := E(ctx, "INSERT INTO users (name) VALUES (?)", nil, "test name")
+//     if err != nil {
+//        return nil, err // Automatic rollback
+//     }
+//     return res.LastInsertId() // Automatic commit
+//  }, nil)
+//
+// Example (custom classifier):
+//
+//  opts := &dbq.TxOptions{IsRetryable: func(err error) bool {
+//     var mErr *mysql.MySQLError
+//     return xerrors.As(err, &mErr) && (mErr.Number == 1213 || mErr.Number == 1205) // Deadlock or lock wait timeout
+//  }}
+//
+func TxResult(ctx context.Context, db interface{}, fn func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) (interface{}, error), opts *TxOptions) (interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var o TxOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	retryPolicy := o.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = ExponentialRetryPolicy(60*time.Second, 3)
+	}
+	o.RetryPolicy = nil
+
+	isRetryable := o.IsRetryable
+	if isRetryable == nil {
+		isRetryable = isRetryableTxError
+	}
+
+	switch db.(type) {
+	case *sql.Tx, *rlSql.Tx:
+		retryPolicy = &backoff.StopBackOff{}
+	}
+
+	var out interface{}
+
+	operation := func() error {
+		var fnErr error
+		out = nil
+
+		err := TxWithOptions(ctx, db, func(tx interface{}, Q QFn, E EFn, txCommit TxCommit) {
+			out, fnErr = fn(tx, Q, E, txCommit)
+			if fnErr == nil {
+				fnErr = txCommit()
+			}
+		}, &o)
+		if perr, ok := err.(*backoff.PermanentError); ok {
+			err = perr.Err
+		}
+		if err == nil {
+			err = fnErr
+		}
+
+		if err != nil && !isRetryable(err) {
+			return &backoff.PermanentError{Err: err}
+		}
+		return err
+	}
+
+	err := backoff.Retry(operation, backoff.WithContext(retryPolicy, ctx))
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// isRetryableTxError reports whether err (or an error it wraps) is a serialization failure or deadlock.
+// PostgreSQL errors are identified by their SQLSTATE code and MySQL errors by their error number.
+func isRetryableTxError(err error) bool {
+	var mErr *mysql.MySQLError
+	if xerrors.As(err, &mErr) {
+		return mErr.Number == 1213
+	}
+
+	var sErr interface{ SQLState() string }
+	if xerrors.As(err, &sErr) {
+		switch sErr.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	return false
 }
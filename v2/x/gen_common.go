@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,14 +18,16 @@ import (
 	// "gopkg.in/cenkalti/backoff.v4"
 )
 
-type res struct{}
+type res struct {
+	rowsAffected int64
+}
 
 func (*res) LastInsertId() (int64, error) {
 	return 0, nil
 }
 
-func (*res) RowsAffected() (int64, error) {
-	return 0, nil
+func (r *res) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
 }
 
 // BulkUpdateOptions is used to configure the BulkUpdate function.
@@ -40,12 +43,42 @@ type BulkUpdateOptions struct {
 	// BulkUpdate works.
 	PrimaryKey string
 
+	// PrimaryKeys can be set instead of PrimaryKey when the primary key is composed of multiple columns
+	// (eg. tenant_id and id). updateData's keys must then be arrays with a value for each column, in the
+	// same order (eg. [2]interface{}{tenantID, id}).
+	PrimaryKeys []string
+
 	// StmtSuffix appends additional sql content to the end of the generated sql statement.
 	StmtSuffix string
 
+	// Returning sets the columns returned by BulkUpdateQ (eg. updated_at).
+	Returning []string
+
+	// NameMapper is used by BulkUpdateStructs to derive the column name of fields that don't have a name set in
+	// their struct tag. If nil, the field's name is used.
+	NameMapper dbq.NameMapper
+
 	// DBType sets the database being used. The default is MySQL.
 	DBType dbq.Database
 
+	// NoQuoteIdentifiers can be set to true to use Table, Columns, the primary key column(s) and Returning
+	// verbatim in the UPDATE statement. By default, they are quoted using dbq.QuoteIdent.
+	NoQuoteIdentifiers bool
+
+	// ColumnTypes sets the type that each column's values are cast to for PostgreSQL (eg. "uuid", "jsonb" or "text[]").
+	// The type of columns that are not provided is guessed from the value's Go type, falling back to TEXT.
+	ColumnTypes map[string]string
+
+	// DiscoverColumnTypes fetches the type of the columns not provided in ColumnTypes from
+	// information_schema.columns for PostgreSQL. db must also be able to query (eg. *sql.DB or *sql.Tx).
+	// The types discovered using a *sql.DB are cached per database, schema and table.
+	// Call ResetColumnTypes after a migration alters a column's type.
+	DiscoverColumnTypes bool
+
+	// MaxPlaceholders sets the maximum number of placeholders per statement. The rows are split into
+	// multiple statements (chunks) so that the limit is respected. The default is dbq.DefaultMaxPlaceholders.
+	MaxPlaceholders int
+
 	// RetryPolicy can be set if you want to retry the query in the event of failure.
 	//
 	// Example:
@@ -57,11 +90,21 @@ type BulkUpdateOptions struct {
 
 // BulkUpdate is used to update multiple rows in a table without a transaction.
 //
-// updateData's key must be the primary key's value in the table.
+// updateData's key must be the primary key's value in the table. For a composite primary key (see PrimaryKeys),
+// it must be an array containing the value of each primary key column.
 //
 // updateData's value is a slice containing the new values for each column. A nil value for a column is acceptable.
 // The slice must be the same length as the number of columns being updated.
 //
+// The rows are updated in the order of their primary keys, so the same data always generates the same statement.
+// If the statement would exceed opts.MaxPlaceholders, the rows are split into multiple statements (chunks) and the
+// rows affected are aggregated. Use a transaction if the chunks must be updated atomically.
+//
+// The table and column names are quoted using dbq.QuoteIdent unless opts.NoQuoteIdentifiers is set.
+//
+// For PostgreSQL, each value is cast to the column's type. The type is guessed from the value's Go type unless
+// it is provided by opts.ColumnTypes or discovered using opts.DiscoverColumnTypes.
+//
 // NOTE: You should perform benchmarks to determine if using a transaction and multiple single-row updates is more efficient for your use-case.
 //
 // Example:
@@ -81,53 +124,440 @@ type BulkUpdateOptions struct {
 //
 func BulkUpdate(ctx context.Context, db dbq.ExecContexter, updateData map[interface{}]interface{}, opts BulkUpdateOptions) (sql.Result, error) {
 
-	if opts.Table == "" || len(opts.Columns) == 0 {
-		return nil, errors.New("no table name or column name(s) provided")
+	if err := opts.validate(); err != nil {
+		return nil, err
 	}
 
 	if len(updateData) == 0 {
 		return &res{}, nil
 	}
 
-	if opts.PrimaryKey == "" {
-		return nil, errors.New("primary key column in database table needs to be specified")
+	rows, err := updateRows(updateData, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	queryArgs := []interface{}{}
+	return bulkUpdate(ctx, db, rows, opts)
+}
 
-	sqlUpdate := fmt.Sprintf("UPDATE %s SET\n", opts.Table)
-	sqlUpdateBack := "\nWHERE " + opts.PrimaryKey + " IN %s"
+// bulkUpdate updates rows in chunks and aggregates the rows affected.
+func bulkUpdate(ctx context.Context, db dbq.ExecContexter, rows []updateRow, opts BulkUpdateOptions) (sql.Result, error) {
+	types, err := columnTypes(ctx, db, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.ColumnTypes = types
 
-	var primaryKeys []interface{}
+	var dbqOpts dbq.Options
+	if opts.RetryPolicy != nil {
+		dbqOpts.RetryPolicy = opts.RetryPolicy
+	}
 
-	var phIdx int
+	out := &res{}
+	for _, chunk := range chunkUpdateRows(rows, opts) {
+		stmt, queryArgs := bulkUpdateStmt(chunk, opts)
 
-	for j, field := range opts.Columns {
+		r, err := dbq.E(ctx, db, stmt, &dbqOpts, queryArgs...)
+		if err != nil {
+			return nil, err
+		}
 
-		eachSet := fmt.Sprintf("%s = CASE\n", field)
+		n, err := r.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		out.rowsAffected += n
+	}
+
+	return out, nil
+}
+
+// MustBulkUpdateQ is a wrapper around the BulkUpdateQ function. It will panic upon encountering an error.
+// This can erradicate boiler-plate error handing code.
+func MustBulkUpdateQ(ctx context.Context, db interface{}, updateData map[interface{}]interface{}, opts BulkUpdateOptions, options *dbq.Options) interface{} {
+	out, err := BulkUpdateQ(ctx, db, updateData, opts, options)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
 
-		for primaryKey, val := range updateData {
-			if j == 0 {
-				primaryKeys = append(primaryKeys, primaryKey)
+// BulkUpdateQ operates the same as BulkUpdate except it returns the opts.Returning columns of the updated rows
+// in the same format as dbq.Q. options is used to decode the rows (eg. ConcreteStruct).
+//
+// For PostgreSQL, a RETURNING clause is appended to the statement. For MySQL, the updated rows are fetched
+// after the statement using their primary keys. db must be a transaction to ensure the rows are not modified
+// before they are fetched.
+//
+// Example:
+//
+//  opts := x.BulkUpdateOptions{
+//     Table:      "tablename",
+//     Columns:    []string{"name", "age"},
+//     PrimaryKey: "id",
+//     Returning:  []string{"id", "updated_at"},
+//     DBType:     dbq.PostgreSQL,
+//  }
+//
+//  results, err := x.BulkUpdateQ(ctx, db, updateData, opts, nil)
+//
+func BulkUpdateQ(ctx context.Context, db interface{}, updateData map[interface{}]interface{}, opts BulkUpdateOptions, options *dbq.Options) (interface{}, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	if len(opts.Returning) == 0 {
+		return nil, errors.New("no returning column(s) provided")
+	}
+
+	var o dbq.Options
+	if options != nil {
+		o = *options
+	}
+	o.SingleResult = false
+	o.KeyBy = ""
+
+	if len(updateData) == 0 {
+		if o.ConcreteStruct == nil {
+			return []map[string]interface{}{}, nil
+		}
+		return reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(reflect.TypeOf(o.ConcreteStruct))), 0, 0).Interface(), nil
+	}
+
+	rows, err := updateRows(updateData, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := columnTypes(ctx, db, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.ColumnTypes = types
+
+	var out reflect.Value
+	for _, chunk := range chunkUpdateRows(rows, opts) {
+		results, err := updateReturning(ctx, db, chunk, opts, o)
+		if err != nil {
+			return nil, err
+		}
+
+		if !out.IsValid() {
+			out = reflect.ValueOf(results)
+		} else {
+			out = reflect.AppendSlice(out, reflect.ValueOf(results))
+		}
+	}
+
+	return out.Interface(), nil
+}
+
+// updateReturning updates a chunk of rows and returns the Returning columns of the updated rows.
+func updateReturning(ctx context.Context, db interface{}, rows []updateRow, opts BulkUpdateOptions, o dbq.Options) (interface{}, error) {
+	stmt, queryArgs := bulkUpdateStmt(rows, opts)
+
+	if opts.DBType == dbq.PostgreSQL {
+		qOpts := o
+		qOpts.RetryPolicy = opts.RetryPolicy
+		return dbq.Q(ctx, db, stmt+" RETURNING "+strings.Join(dbq.QuoteIdentsIf(opts.Returning, opts.DBType, opts.NoQuoteIdentifiers), ","), &qOpts, queryArgs...)
+	}
+
+	_, err := dbq.E(ctx, db.(dbq.ExecContexter), stmt, &dbq.Options{RetryPolicy: opts.RetryPolicy}, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	primaryKeys := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		primaryKeys = append(primaryKeys, row.key)
+	}
+
+	table := dbq.QuoteIdentsIf([]string{opts.Table}, opts.DBType, opts.NoQuoteIdentifiers)[0]
+	returning := dbq.QuoteIdentsIf(opts.Returning, opts.DBType, opts.NoQuoteIdentifiers)
+	pkCols := dbq.QuoteIdentsIf(opts.keyColumns(), opts.DBType, opts.NoQuoteIdentifiers)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(returning, ","), table, keysIn(pkCols, len(rows), 0, opts.DBType))
+	return dbq.Q(ctx, db, query, &o, primaryKeys)
+}
+
+// validate checks that the required options are provided.
+func (opts BulkUpdateOptions) validate() error {
+	if opts.Table == "" || len(opts.Columns) == 0 {
+		return errors.New("no table name or column name(s) provided")
+	}
+
+	if opts.PrimaryKey == "" && len(opts.PrimaryKeys) == 0 {
+		return errors.New("primary key column in database table needs to be specified")
+	}
+	return nil
+}
+
+// keyColumns returns the primary key column(s).
+func (opts BulkUpdateOptions) keyColumns() []string {
+	if len(opts.PrimaryKeys) > 0 {
+		return opts.PrimaryKeys
+	}
+	return []string{opts.PrimaryKey}
+}
+
+// updateRow is a row that is updated by BulkUpdate.
+type updateRow struct {
+	key  []interface{} // value of each primary key column
+	vals reflect.Value // new value of each column
+}
+
+// updateRows converts updateData into rows sorted by their primary key. The sorted order ensures the
+// generated statements are the same for the same data, and that concurrent updates lock rows in the same order.
+func updateRows(updateData map[interface{}]interface{}, opts BulkUpdateOptions) ([]updateRow, error) {
+	nKeys := len(opts.keyColumns())
+
+	out := make([]updateRow, 0, len(updateData))
+	for primaryKey, val := range updateData {
+		key, err := splitKey(primaryKey, nKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		slice := reflect.ValueOf(val)
+		if slice.Kind() != reflect.Slice || len(opts.Columns) != slice.Len() {
+			return nil, errors.New("updateData's value must be a slice with the same length as opts.Columns")
+		}
+
+		out = append(out, updateRow{key: key, vals: slice})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		for k := range out[i].key {
+			if c := compare(out[i].key[k], out[j].key[k]); c != 0 {
+				return c < 0
 			}
+		}
+		return false
+	})
 
-			slice := reflect.ValueOf(val)
-			if len(opts.Columns) != slice.Len() {
-				return nil, errors.New("updateData's value must be a slice with the same length as opts.Columns")
+	return out, nil
+}
+
+// chunkUpdateRows splits rows so that the statement generated for each chunk has at most
+// opts.MaxPlaceholders placeholders.
+func chunkUpdateRows(rows []updateRow, opts BulkUpdateOptions) [][]updateRow {
+	maxPh := opts.MaxPlaceholders
+	if maxPh <= 0 {
+		maxPh = dbq.DefaultMaxPlaceholders
+	}
+
+	nKeys := len(opts.keyColumns())
+
+	out := [][]updateRow{}
+
+	var start, nPh int
+	for i, row := range rows {
+		rowPh := nKeys
+		for j := 0; j < row.vals.Len(); j++ {
+			switch row.vals.Index(j).Interface().(type) {
+			case nil:
+				rowPh += nKeys
+			case unchanged:
+			default:
+				rowPh += nKeys + 1
+			}
+		}
+
+		if i > start && nPh+rowPh > maxPh {
+			out = append(out, rows[start:i])
+			start, nPh = i, 0
+		}
+		nPh += rowPh
+	}
+
+	return append(out, rows[start:])
+}
+
+// compare returns -1, 0 or 1 depending on whether a is less than, equal to or greater than b.
+// Numbers are compared by value. Values of different types are ordered by their type's name.
+func compare(a, b interface{}) int {
+	av, bv := reflect.Indirect(reflect.ValueOf(a)), reflect.Indirect(reflect.ValueOf(b))
+	if !av.IsValid() || !bv.IsValid() {
+		switch {
+		case av.IsValid():
+			return 1
+		case bv.IsValid():
+			return -1
+		}
+		return 0
+	}
+
+	if at, ok := av.Interface().(time.Time); ok {
+		if bt, ok := bv.Interface().(time.Time); ok {
+			switch {
+			case at.Before(bt):
+				return -1
+			case at.After(bt):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if c, ok := compareNumbers(av, bv); ok {
+		return c
+	}
+
+	as, bs := fmt.Sprint(av.Interface()), fmt.Sprint(bv.Interface())
+	if av.Type() != bv.Type() {
+		as, bs = av.Type().String(), bv.Type().String()
+	}
+	return strings.Compare(as, bs)
+}
+
+type numberKind int
+
+const (
+	notNumber numberKind = iota
+	signedNumber
+	unsignedNumber
+	floatNumber
+)
+
+// kindOfNumber classifies the kind of number that v holds.
+func kindOfNumber(v reflect.Value) numberKind {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return signedNumber
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return unsignedNumber
+	case reflect.Float32, reflect.Float64:
+		return floatNumber
+	}
+	return notNumber
+}
+
+// compareNumbers compares 2 numbers by value. Integers are compared exactly, since large values
+// (above 2^53) can't be represented by a float64. ok is false if either value is not a number.
+func compareNumbers(a, b reflect.Value) (c int, ok bool) {
+	ak, bk := kindOfNumber(a), kindOfNumber(b)
+	if ak == notNumber || bk == notNumber {
+		return 0, false
+	}
+
+	if ak == floatNumber || bk == floatNumber {
+		af, bf := toFloat(a, ak), toFloat(b, bk)
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if ak == signedNumber && bk == signedNumber {
+		ai, bi := a.Int(), b.Int()
+		switch {
+		case ai < bi:
+			return -1, true
+		case ai > bi:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if ak == signedNumber && a.Int() < 0 {
+		return -1, true
+	}
+	if bk == signedNumber && b.Int() < 0 {
+		return 1, true
+	}
+
+	au, bu := toUint(a, ak), toUint(b, bk)
+	switch {
+	case au < bu:
+		return -1, true
+	case au > bu:
+		return 1, true
+	}
+	return 0, true
+}
+
+// toFloat converts an integer or float to a float64.
+func toFloat(v reflect.Value, k numberKind) float64 {
+	switch k {
+	case signedNumber:
+		return float64(v.Int())
+	case unsignedNumber:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// toUint converts a non-negative integer to a uint64.
+func toUint(v reflect.Value, k numberKind) uint64 {
+	if k == signedNumber {
+		return uint64(v.Int())
+	}
+	return v.Uint()
+}
+
+// bulkUpdateStmt generates the UPDATE statement and its arguments for rows.
+func bulkUpdateStmt(rows []updateRow, opts BulkUpdateOptions) (string, []interface{}) {
+	queryArgs := []interface{}{}
+
+	table := dbq.QuoteIdentsIf([]string{opts.Table}, opts.DBType, opts.NoQuoteIdentifiers)[0]
+	pkCols := dbq.QuoteIdentsIf(opts.keyColumns(), opts.DBType, opts.NoQuoteIdentifiers)
+
+	var phIdx int
+
+	ph := func() string {
+		phIdx++
+		if opts.DBType == dbq.PostgreSQL {
+			return fmt.Sprintf("$%d", phIdx)
+		}
+		return "?"
+	}
+
+	cond := func(key []interface{}) string {
+		conds := make([]string, 0, len(key))
+		for k, pkCol := range pkCols {
+			conds = append(conds, fmt.Sprintf("%s = %s", pkCol, ph()))
+			queryArgs = append(queryArgs, key[k])
+		}
+		if len(conds) == 1 {
+			return conds[0]
+		}
+		return "(" + strings.Join(conds, " AND ") + ")"
+	}
+
+	sqlUpdate := fmt.Sprintf("UPDATE %s SET\n", table)
+
+	for j, field := range opts.Columns {
+
+		var changed bool
+		for _, row := range rows {
+			if _, ok := row.vals.Index(j).Interface().(unchanged); !ok {
+				changed = true
+				break
 			}
+		}
+		if !changed {
+			continue
+		}
+
+		col := dbq.QuoteIdentsIf([]string{field}, opts.DBType, opts.NoQuoteIdentifiers)[0]
+		eachSet := fmt.Sprintf("%s = CASE\n", col)
 
-			valJVal := slice.Index(j)
+		var keep bool
+		for _, row := range rows {
+			key := row.key
+			valJVal := row.vals.Index(j)
 			valJ := valJVal.Interface()
 
-			if valJ == nil {
-				if opts.DBType == dbq.PostgreSQL {
-					eachSet = eachSet + fmt.Sprintf("\tWHEN %v = $%d THEN NULL\n", opts.PrimaryKey, phIdx+1)
-					phIdx++
-				} else {
-					eachSet = eachSet + fmt.Sprintf("\tWHEN %v = ? THEN NULL\n", opts.PrimaryKey)
-				}
+			if _, ok := valJ.(unchanged); ok {
+				keep = true
+				continue
+			}
 
-				queryArgs = append(queryArgs, primaryKey)
+			if valJ == nil {
+				eachSet = eachSet + fmt.Sprintf("\tWHEN %s THEN NULL\n", cond(key))
 			} else {
 
 				var v interface{}
@@ -144,8 +574,8 @@ func BulkUpdate(ctx context.Context, db dbq.ExecContexter, updateData map[interf
 
 				if opts.DBType == dbq.PostgreSQL {
 
-					var colType string
-					if v != nil {
+					colType, exists := opts.ColumnTypes[field]
+					if !exists && v != nil {
 						switch v.(type) {
 						case uint, int, *uint, *int:
 							colType = "INT"
@@ -172,33 +602,63 @@ func BulkUpdate(ctx context.Context, db dbq.ExecContexter, updateData map[interf
 						}
 					}
 
-					eachSet = eachSet + fmt.Sprintf("WHEN %v = $%d THEN $%d::%s\n", opts.PrimaryKey, phIdx+1, phIdx+2, colType)
-					phIdx += 2
+					c := cond(key)
+					eachSet = eachSet + fmt.Sprintf("WHEN %s THEN %s::%s\n", c, ph(), colType)
 				} else {
-					eachSet = eachSet + fmt.Sprintf("WHEN %v = ? THEN ?\n", opts.PrimaryKey)
+					c := cond(key)
+					eachSet = eachSet + fmt.Sprintf("WHEN %s THEN %s\n", c, ph())
 				}
-				queryArgs = append(queryArgs, primaryKey, v)
+				queryArgs = append(queryArgs, v)
 			}
 		}
 
+		if keep {
+
+			eachSet = eachSet + fmt.Sprintf("\tELSE %s\n", col)
+		}
 		eachSet = eachSet + "END,\n"
 
 		sqlUpdate = fmt.Sprintf("%s %s", sqlUpdate, eachSet)
 	}
 	sqlUpdate = strings.TrimSuffix(sqlUpdate, ",\n")
 
-	stmt := sqlUpdate + fmt.Sprintf(sqlUpdateBack, dbq.Ph(len(primaryKeys), 1, phIdx, opts.DBType))
+	stmt := sqlUpdate + "\nWHERE " + keysIn(pkCols, len(rows), phIdx, opts.DBType)
 
 	if opts.StmtSuffix != "" {
 		stmt = stmt + " " + opts.StmtSuffix
 	}
 
-	queryArgs = append(queryArgs, primaryKeys...)
+	for _, row := range rows {
+		queryArgs = append(queryArgs, row.key...)
+	}
+
+	return stmt, queryArgs
+}
 
-	var dbqOpts dbq.Options
-	if opts.RetryPolicy != nil {
-		dbqOpts.RetryPolicy = opts.RetryPolicy
+// keysIn generates the condition that matches nKeys rows using their primary key column(s).
+// Row values are used for composite primary keys (eg. (a,b) IN ((?,?),(?,?))).
+func keysIn(pkCols []string, nKeys int, incr int, dbtype dbq.Database) string {
+	if len(pkCols) == 1 {
+		return pkCols[0] + " IN " + dbq.Ph(nKeys, 1, incr, dbtype)
 	}
+	return "(" + strings.Join(pkCols, ",") + ") IN (" + dbq.Ph(len(pkCols), nKeys, incr, dbtype) + ")"
+}
 
-	return dbq.E(ctx, db, stmt, &dbqOpts, queryArgs...)
+// splitKey returns the values of each primary key column from a key (eg. updateData's key).
+// A composite primary key must be provided as an array.
+func splitKey(key interface{}, n int) ([]interface{}, error) {
+	if n == 1 {
+		return []interface{}{key}, nil
+	}
+
+	k := reflect.ValueOf(key)
+	if k.Kind() != reflect.Array || k.Len() != n {
+		return nil, fmt.Errorf("key must be an array with %d values for the composite primary key", n)
+	}
+
+	out := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, k.Index(i).Interface())
+	}
+	return out, nil
 }
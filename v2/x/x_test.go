@@ -0,0 +1,506 @@
+package x
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/rocketlaunchr/dbq/v2"
+)
+
+type user struct {
+	ID   int64  `dbq:"id"`
+	Name string `dbq:"name"`
+}
+
+func TestBulkUpdateQ(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	updateData := map[interface{}]interface{}{
+		2: []interface{}{"b", nil},
+		1: []interface{}{"a", 5},
+	}
+
+	expected := []*user{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+
+	// PostgreSQL
+	mock.ExpectQuery(`UPDATE "users" SET "name" = CASE WHEN "id" = $1 THEN $2::VARCHAR WHEN "id" = $3 THEN $4::VARCHAR END, "age" = CASE WHEN "id" = $5 THEN $6::INT WHEN "id" = $7 THEN NULL END WHERE "id" IN ($8,$9) RETURNING "id","name"`).
+		WithArgs(1, "a", 2, "b", 1, 5, 2, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "a").AddRow(int64(2), "b"))
+
+	opts := BulkUpdateOptions{
+		Table:      "users",
+		Columns:    []string{"name", "age"},
+		PrimaryKey: "id",
+		Returning:  []string{"id", "name"},
+		DBType:     dbq.PostgreSQL,
+	}
+
+	actual, err := BulkUpdateQ(context.Background(), db, updateData, opts, &dbq.Options{ConcreteStruct: user{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !cmp.Equal(expected, actual) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, actual))
+	}
+
+	// MySQL
+	mock.ExpectExec("UPDATE `users` SET `name` = CASE WHEN `id` = ? THEN ? WHEN `id` = ? THEN ? END, `age` = CASE WHEN `id` = ? THEN ? WHEN `id` = ? THEN NULL END WHERE `id` IN ( ?,? )").
+		WithArgs(1, "a", 2, "b", 1, 5, 2, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT `id`,`name` FROM `users` WHERE `id` IN ( ?,? )").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "a").AddRow(int64(2), "b"))
+
+	opts.DBType = dbq.MySQL
+
+	actual, err = BulkUpdateQ(context.Background(), db, updateData, opts, &dbq.Options{ConcreteStruct: user{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !cmp.Equal(expected, actual) {
+		t.Errorf("wrong val: %s", cmp.Diff(expected, actual))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBulkUpdateCompositeKey(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	updateData := map[interface{}]interface{}{
+		[2]int{1, 5}: []interface{}{"a"},
+		[2]int{1, 3}: []interface{}{"b"},
+	}
+
+	opts := BulkUpdateOptions{
+		Table:       "users",
+		Columns:     []string{"name"},
+		PrimaryKeys: []string{"tenant_id", "id"},
+	}
+
+	// MySQL
+	mock.ExpectExec("UPDATE `users` SET `name` = CASE WHEN (`tenant_id` = ? AND `id` = ?) THEN ? WHEN (`tenant_id` = ? AND `id` = ?) THEN ? END WHERE (`tenant_id`,`id`) IN (( ?,? ),( ?,? ))").
+		WithArgs(1, 3, "b", 1, 5, "a", 1, 3, 1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if _, err := BulkUpdate(context.Background(), db, updateData, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// PostgreSQL
+	mock.ExpectExec(`UPDATE "users" SET "name" = CASE WHEN ("tenant_id" = $1 AND "id" = $2) THEN $3::VARCHAR WHEN ("tenant_id" = $4 AND "id" = $5) THEN $6::VARCHAR END WHERE ("tenant_id","id") IN (($7,$8),($9,$10))`).
+		WithArgs(1, 3, "b", 1, 5, "a", 1, 3, 1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	opts.DBType = dbq.PostgreSQL
+	if _, err := BulkUpdate(context.Background(), db, updateData, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Keys must be arrays
+	if _, err := BulkUpdate(context.Background(), db, map[interface{}]interface{}{1: []interface{}{"a"}}, opts); err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{int64(9007199254740993), int64(9007199254740992), 1},
+		{uint64(18446744073709551615), uint64(18446744073709551614), 1},
+		{int64(-1), uint64(0), -1},
+		{uint64(9007199254740993), int64(9007199254740993), 0},
+		{int(2), float64(1.5), 1},
+		{float32(1.5), uint8(2), -1},
+		{nil, 1, -1},
+		{"b", "a", 1},
+		{"a", 1, 1}, // int < string
+	}
+
+	for _, tc := range tests {
+		if actual := compare(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("compare(%v, %v): expected: %d actual: %d", tc.a, tc.b, tc.expected, actual)
+		}
+		if actual := compare(tc.b, tc.a); actual != -tc.expected {
+			t.Errorf("compare(%v, %v): expected: %d actual: %d", tc.b, tc.a, -tc.expected, actual)
+		}
+	}
+}
+
+func TestBulkUpdateChunks(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	updateData := map[interface{}]interface{}{
+		int64(9007199254740993): []interface{}{"c"},
+		int64(9007199254740992): []interface{}{"b"},
+		int64(1):                []interface{}{nil},
+	}
+
+	// Each row requires 2 placeholders (or 1 for NULL) plus 1 for the WHERE clause
+	mock.ExpectExec("UPDATE `users` SET `name` = CASE WHEN `id` = ? THEN NULL WHEN `id` = ? THEN ? END WHERE `id` IN ( ?,? )").
+		WithArgs(1, int64(9007199254740992), "b", 1, int64(9007199254740992)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE `users` SET `name` = CASE WHEN `id` = ? THEN ? END WHERE `id` IN ( ? )").
+		WithArgs(int64(9007199254740993), "c", int64(9007199254740993)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	opts := BulkUpdateOptions{
+		Table:           "users",
+		Columns:         []string{"name"},
+		PrimaryKey:      "id",
+		MaxPlaceholders: 5,
+	}
+
+	res, err := BulkUpdate(context.Background(), db, updateData, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+type account struct {
+	ID   int    `dbq:"id,pk"`
+	Name string `dbq:"name"`
+	Age  int
+	Meta map[string]string
+}
+
+func TestBulkUpdateStructs(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	original := []account{{1, "a", 5, nil}, {2, "b", 8, nil}, {3, "c", 9, nil}}
+	modified := []*account{{1, "a", 6, nil}, {2, "z", 8, nil}, {3, "c", 9, nil}, {4, "d", 1, nil}}
+
+	// Row 3 is unchanged. Rows 1 and 2 retain the columns they don't change. Row 4 has no original.
+	mock.ExpectExec("UPDATE `accounts` SET `name` = CASE WHEN `id` = ? THEN ? WHEN `id` = ? THEN ? ELSE `name` END, `age` = CASE WHEN `id` = ? THEN ? WHEN `id` = ? THEN ? ELSE `age` END WHERE `id` IN ( ?,?,? )").
+		WithArgs(2, "z", 4, "d", 1, 6, 4, 1, 1, 2, 4).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	opts := BulkUpdateOptions{Table: "accounts", NameMapper: dbq.SnakeCase}
+
+	res, err := BulkUpdateStructs(context.Background(), db, modified, original, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: %d", n)
+	}
+
+	// Only the changed columns are updated
+	mock.ExpectExec("UPDATE `accounts` SET `name` = CASE WHEN `id` = ? THEN ? END WHERE `id` IN ( ? )").
+		WithArgs(2, "z", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := BulkUpdateStructs(context.Background(), db, modified[1:3], original, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Chunks only update the columns that their rows change
+	mock.ExpectExec("UPDATE `accounts` SET `age` = CASE WHEN `id` = ? THEN ? END WHERE `id` IN ( ? )").
+		WithArgs(1, 6, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `accounts` SET `name` = CASE WHEN `id` = ? THEN ? END WHERE `id` IN ( ? )").
+		WithArgs(2, "z", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	chunkOpts := opts
+	chunkOpts.MaxPlaceholders = 3
+	if _, err := BulkUpdateStructs(context.Background(), db, modified[:2], original, chunkOpts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// No changes
+	if _, err := BulkUpdateStructs(context.Background(), db, original, original, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Tagged fields that can't be bound
+	type invalid struct {
+		ID   int               `dbq:"id,pk"`
+		Meta map[string]string `dbq:"meta"`
+	}
+
+	if _, err := BulkUpdateStructs(context.Background(), db, []invalid{{ID: 1}}, nil, opts); err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+type execOnly struct{ dbq.ExecContexter }
+
+func TestDiscoverColumnTypes(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	defer ResetColumnTypes()
+
+	discoverQuery := "SELECT column_name, data_type, udt_schema, udt_name FROM information_schema.columns WHERE table_name = $1 AND table_schema = $2"
+	columns := []string{"column_name", "data_type", "udt_schema", "udt_name"}
+
+	updateData := map[interface{}]interface{}{1: []interface{}{"active", []string{"a"}}}
+	updateQuery := `UPDATE "users" SET "status" = CASE WHEN "id" = $1 THEN $2::"public"."status" END, "tags" = CASE WHEN "id" = $3 THEN $4::text[] END WHERE "id" IN ($5)`
+
+	opts := BulkUpdateOptions{
+		Table:               "users",
+		Columns:             []string{"status", "tags"},
+		PrimaryKey:          "id",
+		DBType:              dbq.PostgreSQL,
+		DiscoverColumnTypes: true,
+	}
+
+	// Types are discovered using the current schema
+	mock.ExpectQuery("SELECT current_schema()").
+		WillReturnRows(sqlmock.NewRows([]string{"current_schema"}).AddRow("public"))
+	mock.ExpectQuery(discoverQuery).
+		WithArgs("users", "public").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("id", "integer", "pg_catalog", "int4").
+			AddRow("status", "USER-DEFINED", "public", "status").
+			AddRow("tags", "ARRAY", "pg_catalog", "_text"))
+	mock.ExpectExec(updateQuery).
+		WithArgs(1, "active", 1, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := BulkUpdate(context.Background(), db, updateData, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Cached types are reused
+	mock.ExpectQuery("SELECT current_schema()").
+		WillReturnRows(sqlmock.NewRows([]string{"current_schema"}).AddRow("public"))
+	mock.ExpectExec(updateQuery).
+		WithArgs(1, "active", 1, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := BulkUpdate(context.Background(), db, updateData, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Tables of other schemas are discovered separately
+	mock.ExpectQuery(discoverQuery).
+		WithArgs("users", "audit").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("status", "text", "pg_catalog", "text").
+			AddRow("tags", "ARRAY", "pg_catalog", "_varchar"))
+	mock.ExpectExec(`UPDATE "audit"."users" SET "status" = CASE WHEN "id" = $1 THEN $2::text END, "tags" = CASE WHEN "id" = $3 THEN $4::varchar[] END WHERE "id" IN ($5)`).
+		WithArgs(1, "active", 1, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	auditOpts := opts
+	auditOpts.Table = "audit.users"
+	if _, err := BulkUpdate(context.Background(), db, updateData, auditOpts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Types are discovered again after a reset
+	ResetColumnTypes()
+
+	mock.ExpectQuery("SELECT current_schema()").
+		WillReturnRows(sqlmock.NewRows([]string{"current_schema"}).AddRow("public"))
+	mock.ExpectQuery(discoverQuery).
+		WithArgs("users", "public").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("status", "text", "pg_catalog", "text").
+			AddRow("tags", "ARRAY", "pg_catalog", "_text"))
+	mock.ExpectExec(`UPDATE "users" SET "status" = CASE WHEN "id" = $1 THEN $2::text END, "tags" = CASE WHEN "id" = $3 THEN $4::text[] END WHERE "id" IN ($5)`).
+		WithArgs(1, "active", 1, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := BulkUpdate(context.Background(), db, updateData, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// db must be able to query
+	if _, err := BulkUpdate(context.Background(), execOnly{db}, updateData, opts); err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+}
+
+func TestBulkDelete(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// MySQL: keys are sorted and split into chunks
+	mock.ExpectExec("DELETE FROM `users` WHERE `id` IN ( ?,? ) LIMIT 10").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM `users` WHERE `id` IN ( ? ) LIMIT 10").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	opts := BulkDeleteOptions{
+		Table:           "users",
+		PrimaryKey:      "id",
+		StmtSuffix:      "LIMIT 10",
+		MaxPlaceholders: 2,
+	}
+
+	r, err := BulkDelete(context.Background(), db, []interface{}{3, 1, 2}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n, _ := r.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: expected 3 actual %d", n)
+	}
+
+	// PostgreSQL: composite primary key
+	mock.ExpectExec(`DELETE FROM "users" WHERE ("tenant_id","id") IN (($1,$2),($3,$4))`).
+		WithArgs(1, 3, 1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	opts = BulkDeleteOptions{
+		Table:       "users",
+		PrimaryKeys: []string{"tenant_id", "id"},
+		DBType:      dbq.PostgreSQL,
+	}
+
+	if _, err := BulkDelete(context.Background(), db, []interface{}{[2]int{1, 5}, [2]int{1, 3}}, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Keys must be arrays
+	if _, err := BulkDelete(context.Background(), db, []interface{}{1}, opts); err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+
+	// No keys
+	r, err = BulkDelete(context.Background(), db, nil, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n, _ := r.RowsAffected(); n != 0 {
+		t.Errorf("wrong rows affected: expected 0 actual %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBulkUpsert(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := []interface{}{
+		[]interface{}{1, "rabbit", 5},
+		[]interface{}{2, "cat", 8},
+	}
+
+	// MySQL
+	mock.ExpectExec("INSERT INTO `users` ( `id`,`name`,`age` ) VALUES ( ?,?,? ),( ?,?,? ) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`),`age` = VALUES(`age`)").
+		WithArgs(1, "rabbit", 5, 2, "cat", 8).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	opts := BulkUpsertOptions{
+		Table:      "users",
+		Columns:    []string{"id", "name", "age"},
+		PrimaryKey: "id",
+	}
+
+	r, err := BulkUpsert(context.Background(), db, rows, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n, _ := r.RowsAffected(); n != 3 {
+		t.Errorf("wrong rows affected: expected 3 actual %d", n)
+	}
+
+	// PostgreSQL: rows are split into chunks
+	mock.ExpectExec(`INSERT INTO "users" ( "id","name","age" ) VALUES ( $1,$2,$3 ) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`).
+		WithArgs(1, "rabbit", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "users" ( "id","name","age" ) VALUES ( $1,$2,$3 ) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`).
+		WithArgs(2, "cat", 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	opts.UpdateColumns = []string{"name"}
+	opts.DBType = dbq.PostgreSQL
+	opts.MaxPlaceholders = 3
+
+	r, err = BulkUpsert(context.Background(), db, rows, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n, _ := r.RowsAffected(); n != 2 {
+		t.Errorf("wrong rows affected: expected 2 actual %d", n)
+	}
+
+	// Quoting disabled
+	mock.ExpectExec(`INSERT INTO "Users" ( id,name,age ) VALUES ( $1,$2,$3 ),( $4,$5,$6 ) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name`).
+		WithArgs(1, "rabbit", 5, 2, "cat", 8).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	noQuoteOpts := opts
+	noQuoteOpts.Table = `"Users"`
+	noQuoteOpts.NoQuoteIdentifiers = true
+	noQuoteOpts.MaxPlaceholders = 0
+
+	if _, err := BulkUpsert(context.Background(), db, rows, noQuoteOpts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// PostgreSQL requires a conflict target
+	opts.PrimaryKey = ""
+	if _, err := BulkUpsert(context.Background(), db, rows, opts); err == nil {
+		t.Errorf("was expecting an error, but there was none.")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}